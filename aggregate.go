@@ -0,0 +1,190 @@
+package xbase
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AggFunc is an aggregate function applied to a numeric field.
+type AggFunc int
+
+const (
+	// AggSum is the sum of the values.
+	AggSum AggFunc = iota
+	// AggAvg is the arithmetic mean of the values.
+	AggAvg
+	// AggMin is the smallest value.
+	AggMin
+	// AggMax is the largest value.
+	AggMax
+	// AggCount is the number of values.
+	AggCount
+)
+
+// accumulator collects the values of one aggregation.
+type accumulator struct {
+	sum, min, max float64
+	count         int64
+}
+
+func (a *accumulator) add(v float64) {
+	if a.count == 0 || v < a.min {
+		a.min = v
+	}
+	if a.count == 0 || v > a.max {
+		a.max = v
+	}
+	a.sum += v
+	a.count++
+}
+
+func (a *accumulator) result(fn AggFunc) float64 {
+	switch fn {
+	case AggAvg:
+		if a.count == 0 {
+			return 0
+		}
+		return a.sum / float64(a.count)
+	case AggMin:
+		return a.min
+	case AggMax:
+		return a.max
+	case AggCount:
+		return float64(a.count)
+	}
+	return a.sum
+}
+
+// Aggregate applies fn to the values of the numeric field of all not deleted
+// records. Blank values count as 0. A header row, see SetFirstRecordIsHeader,
+// is skipped. It doesn't move the cursor.
+// Fields are numbered starting from 1.
+func (db *XBase) Aggregate(fieldNo int, fn AggFunc) (float64, error) {
+	if fn < AggSum || fn > AggCount {
+		return 0, fmt.Errorf("xbase: Aggregate: unknown function %d", fn)
+	}
+	if fieldNo < 1 || fieldNo > len(db.fields) {
+		return 0, fmt.Errorf("xbase: Aggregate: field number %d out of range", fieldNo)
+	}
+	f := db.fields[fieldNo-1]
+	if err := f.checkNumValue(); err != nil {
+		return 0, fmt.Errorf("xbase: Aggregate: field %q: %w", f.name(), err)
+	}
+	var acc accumulator
+	err := db.scanFrom(db.firstRecNo(), func(_ int64, buf []byte) error {
+		if buf[0] == '*' {
+			return nil
+		}
+		v, err := f.numValue(buf)
+		if err != nil {
+			return fmt.Errorf("xbase: Aggregate: field %q: %w", f.name(), err)
+		}
+		acc.add(v)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return acc.result(fn), nil
+}
+
+// GroupBy groups the not deleted records by the trimmed string value of
+// keyField and applies fn to the values of the numeric valueField in every
+// group. A header row, see SetFirstRecordIsHeader, is skipped. It doesn't
+// move the cursor.
+func (db *XBase) GroupBy(keyField string, valueField string, fn AggFunc) (map[string]float64, error) {
+	if fn < AggSum || fn > AggCount {
+		return nil, fmt.Errorf("xbase: GroupBy: unknown function %d", fn)
+	}
+	kf, err := db.fieldByName("GroupBy", keyField)
+	if err != nil {
+		return nil, err
+	}
+	vf, err := db.fieldByName("GroupBy", valueField)
+	if err != nil {
+		return nil, err
+	}
+	if err := vf.checkNumValue(); err != nil {
+		return nil, fmt.Errorf("xbase: GroupBy: field %q: %w", vf.name(), err)
+	}
+	groups := make(map[string]*accumulator)
+	err = db.scanFrom(db.firstRecNo(), func(_ int64, buf []byte) error {
+		if buf[0] == '*' {
+			return nil
+		}
+		key, err := kf.stringValue(buf, db.decoder)
+		if err != nil {
+			return fmt.Errorf("xbase: GroupBy: field %q: %w", kf.name(), err)
+		}
+		v, err := vf.numValue(buf)
+		if err != nil {
+			return fmt.Errorf("xbase: GroupBy: field %q: %w", vf.name(), err)
+		}
+		key = strings.TrimSpace(key)
+		acc, ok := groups[key]
+		if !ok {
+			acc = &accumulator{}
+			groups[key] = acc
+		}
+		acc.add(v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]float64, len(groups))
+	for k, acc := range groups {
+		res[k] = acc.result(fn)
+	}
+	return res, nil
+}
+
+// CountOption configures Count.
+type CountOption func(*countOptions)
+
+type countOptions struct {
+	includeDeleted bool
+}
+
+// CountIncludeDeleted makes Count consider the deleted records too.
+func CountIncludeDeleted() CountOption {
+	return func(o *countOptions) {
+		o.includeDeleted = true
+	}
+}
+
+// Count returns the number of not deleted records for which filter returns
+// true. filter gets the trimmed string values of the fields, the slice is
+// reused for every record and must not be retained. A nil filter counts all
+// the records. It doesn't move the cursor.
+func (db *XBase) Count(filter func(rec []string) bool, opts ...CountOption) (int64, error) {
+	var o countOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var n int64
+	rec := make([]string, len(db.fields))
+	err := db.scan(func(recNo int64, buf []byte) error {
+		if recNo < db.firstRecNo() || !o.includeDeleted && buf[0] == '*' {
+			return nil
+		}
+		if filter != nil {
+			for i, f := range db.fields {
+				s, err := f.stringValue(buf, db.decoder)
+				if err != nil {
+					return fmt.Errorf("xbase: Count: field %q: %w", f.name(), err)
+				}
+				rec[i] = strings.TrimSpace(s)
+			}
+			if !filter(rec) {
+				return nil
+			}
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,114 @@
+package xbase
+
+import (
+	"fmt"
+	"io"
+)
+
+// bufferedSeeker buffers the writes to a seeker. Consecutive writes are
+// collected in memory and written at once, a seek only moves the logical
+// position, so appending records doesn't cost a syscall per record.
+// The buffer is written out before reads, before writes elsewhere in the
+// file and by Flush.
+type bufferedSeeker struct {
+	rws io.ReadWriteSeeker
+	// buf holds the pending writes starting at the offset off
+	buf []byte
+	off int64
+	// pos is the logical position
+	pos int64
+	// size is the file size including the pending writes, -1 if unknown
+	size int64
+}
+
+func newBufferedSeeker(rws io.ReadWriteSeeker, size int) (*bufferedSeeker, error) {
+	pos, err := rws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	return &bufferedSeeker{rws: rws, buf: make([]byte, 0, size), pos: pos, size: -1}, nil
+}
+
+func (b *bufferedSeeker) Read(p []byte) (int, error) {
+	if err := b.Flush(); err != nil {
+		return 0, err
+	}
+	if _, err := b.rws.Seek(b.pos, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := b.rws.Read(p)
+	b.pos += int64(n)
+	return n, err
+}
+
+func (b *bufferedSeeker) Write(p []byte) (int, error) {
+	if len(b.buf) > 0 && b.pos != b.off+int64(len(b.buf)) || len(b.buf)+len(p) > cap(b.buf) {
+		if err := b.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	if len(p) > cap(b.buf) {
+		if _, err := b.rws.Seek(b.pos, io.SeekStart); err != nil {
+			return 0, err
+		}
+		n, err := b.rws.Write(p)
+		b.advance(n)
+		return n, err
+	}
+	if len(b.buf) == 0 {
+		b.off = b.pos
+	}
+	b.buf = append(b.buf, p...)
+	b.advance(len(p))
+	return len(p), nil
+}
+
+// advance moves the position after n written bytes.
+func (b *bufferedSeeker) advance(n int) {
+	b.pos += int64(n)
+	if b.size >= 0 && b.pos > b.size {
+		b.size = b.pos
+	}
+}
+
+func (b *bufferedSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += b.pos
+	case io.SeekEnd:
+		if b.size < 0 {
+			if err := b.Flush(); err != nil {
+				return 0, err
+			}
+			size, err := b.rws.Seek(0, io.SeekEnd)
+			if err != nil {
+				return 0, err
+			}
+			b.size = size
+		}
+		offset += b.size
+	default:
+		return 0, fmt.Errorf("seek whence is not valid: (%d)", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("seek to negative position: (%d)", offset)
+	}
+	b.pos = offset
+	return offset, nil
+}
+
+// Flush writes the pending writes to the seeker.
+func (b *bufferedSeeker) Flush() error {
+	if len(b.buf) == 0 {
+		return nil
+	}
+	if _, err := b.rws.Seek(b.off, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := b.rws.Write(b.buf); err != nil {
+		return err
+	}
+	b.buf = b.buf[:0]
+	return nil
+}
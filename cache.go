@@ -7,11 +7,12 @@ import (
 )
 
 type fieldDescription struct {
-	name     string
-	baseType reflect.Type
-	typ      reflect.Type
-	tag      tag
-	index    []int
+	name      string
+	fieldName string // name of the Go struct field
+	baseType  reflect.Type
+	typ       reflect.Type
+	tag       tag
+	index     []int
 }
 
 type fieldDescriptions []fieldDescription
@@ -122,7 +123,8 @@ func buildFields(k typeKey) fieldDescriptions {
 				continue
 			}
 			if f.tag.prefix != "" {
-				tag.prefix += f.tag.prefix
+				// the prefix of the parent comes first
+				tag.prefix = f.tag.prefix + tag.prefix
 			}
 
 			ft := sf.Type
@@ -131,11 +133,12 @@ func buildFields(k typeKey) fieldDescriptions {
 			}
 
 			newf := fieldDescription{
-				name:     tag.prefix + tag.name,
-				baseType: sf.Type,
-				typ:      ft,
-				tag:      tag,
-				index:    makeIndex(f.index, i),
+				name:      tag.prefix + tag.name,
+				fieldName: sf.Name,
+				baseType:  sf.Type,
+				typ:       ft,
+				tag:       tag,
+				index:     makeIndex(f.index, i),
 			}
 
 			if sf.Anonymous && ft.Kind() == reflect.Struct && tag.empty {
@@ -159,11 +162,12 @@ func buildFields(k typeKey) fieldDescriptions {
 				if v.typ == f.typ && v.tag.prefix == tag.prefix {
 					// other nodes can have different path.
 					fm.insert(fieldDescription{
-						name:     tag.prefix + tag.name,
-						baseType: sf.Type,
-						typ:      ft,
-						tag:      tag,
-						index:    makeIndex(v.index, i),
+						name:      tag.prefix + tag.name,
+						fieldName: sf.Name,
+						baseType:  sf.Type,
+						typ:       ft,
+						tag:       tag,
+						index:     makeIndex(v.index, i),
 					})
 				}
 			}
@@ -2,6 +2,17 @@ package xbase
 
 import "golang.org/x/text/encoding/charmap"
 
+// OnUnmappable defines how characters that can't be represented in a code
+// page are handled.
+type OnUnmappable int
+
+const (
+	// UnmappableError fails with an error.
+	UnmappableError OnUnmappable = iota
+	// UnmappableReplace replaces the character with '?'.
+	UnmappableReplace
+)
+
 type cPage struct {
 	code byte
 	page int
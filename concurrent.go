@@ -0,0 +1,94 @@
+package xbase
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ConcurrentReader reads records of a DBF file by their number with
+// io.ReaderAt. It has no cursor, so it is safe for concurrent use by
+// multiple goroutines without locking.
+type ConcurrentReader struct {
+	r    io.ReaderAt
+	size int64
+	db   *XBase
+	cm   *charmap.Charmap
+}
+
+// OpenReaderAt parses the header and the field descriptors of the DBF file
+// of the given size read from r and returns a ConcurrentReader.
+func OpenReaderAt(r io.ReaderAt, size int64) (*ConcurrentReader, error) {
+	h := make([]byte, headerSize)
+	if _, err := r.ReadAt(h, 0); err != nil {
+		return nil, fmt.Errorf("xbase: OpenReaderAt: %w", err)
+	}
+	hdr := &header{}
+	if err := hdr.read(bytes.NewReader(h)); err != nil {
+		return nil, fmt.Errorf("xbase: OpenReaderAt: %w", err)
+	}
+	h = make([]byte, hdr.DataOffset)
+	if _, err := r.ReadAt(h, 0); err != nil {
+		return nil, fmt.Errorf("xbase: OpenReaderAt: %w", err)
+	}
+	db, err := New(NewSeekableBufferWithBytes(h))
+	if err != nil {
+		return nil, fmt.Errorf("xbase: OpenReaderAt: %w", err)
+	}
+	db.readOnly = true
+	return &ConcurrentReader{r: r, size: size, db: db, cm: charMapByPage(db.CodePage())}, nil
+}
+
+// Fields returns the field names.
+func (c *ConcurrentReader) Fields() []string {
+	return c.db.Fields()
+}
+
+// RecCount returns the number of records, including the deleted ones.
+// Records past the end of the file, e.g. of a truncated file, aren't counted.
+func (c *ConcurrentReader) RecCount() int64 {
+	n := c.db.recCount()
+	if recSize := int64(c.db.header.RecSize); recSize > 0 {
+		if avail := (c.size - int64(c.db.header.DataOffset)) / recSize; avail < n {
+			n = avail
+		}
+	}
+	return n
+}
+
+// ReadRecord returns the trimmed string values of the record recNo,
+// numbered from 1, like XBase.Read. Deleted records are returned too.
+func (c *ConcurrentReader) ReadRecord(recNo int64) ([]string, error) {
+	if recNo < 1 {
+		return nil, BOF
+	}
+	if recNo > c.RecCount() {
+		return nil, io.EOF
+	}
+	buf := make([]byte, c.db.header.RecSize)
+	off := int64(c.db.header.DataOffset) + int64(len(buf))*(recNo-1)
+	if _, err := c.r.ReadAt(buf, off); err != nil {
+		return nil, fmt.Errorf("xbase: ReadRecord: record %d: %w", recNo, err)
+	}
+	// each call has its own decoder, decoders aren't safe for concurrent use
+	var dec *encoding.Decoder
+	if c.cm != nil {
+		dec = c.cm.NewDecoder()
+	}
+	row := make([]string, 0, len(c.db.fields))
+	for _, f := range c.db.fields {
+		if err := f.checkBuffer(buf); err != nil {
+			return nil, err
+		}
+		s, err := f.stringValue(buf, dec)
+		if err != nil {
+			return nil, fmt.Errorf("xbase: ReadRecord: record %d field %q: %w", recNo, f.name(), err)
+		}
+		row = append(row, strings.TrimSpace(s))
+	}
+	return row, nil
+}
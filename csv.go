@@ -0,0 +1,125 @@
+package xbase
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// defaultCSVFlushEvery is the number of records imported between flushes.
+const defaultCSVFlushEvery = 10000
+
+// CSVOption configures ImportCSVStream.
+type CSVOption func(*csvOptions)
+
+type csvOptions struct {
+	skipHeader bool
+	comma      rune
+	flushEvery int
+}
+
+// CSVSkipHeader makes ImportCSVStream skip the first CSV record.
+func CSVSkipHeader() CSVOption {
+	return func(o *csvOptions) {
+		o.skipHeader = true
+	}
+}
+
+// CSVComma sets the field delimiter of the CSV input, ',' by default.
+func CSVComma(r rune) CSVOption {
+	return func(o *csvOptions) {
+		o.comma = r
+	}
+}
+
+// CSVFlushEvery sets the number of records ImportCSVStream appends between
+// writing the header, 10000 by default.
+func CSVFlushEvery(n int) CSVOption {
+	return func(o *csvOptions) {
+		o.flushEvery = n
+	}
+}
+
+// ImportCSVStream appends the records of the CSV input r to the DBF file,
+// reading one record at a time so that the memory use doesn't depend on
+// the size of the input. The CSV values are assigned to the fields in order
+// and converted to their types like SetLenientSet does, blank values leave the
+// field blank. The header is flushed every CSVFlushEvery records and at the
+// end.
+//
+// It returns the number of records imported. On error the records before the
+// failing one stay imported and the error names the CSV record number,
+// counting the header. It's the line number unless quoted values span lines.
+func (db *XBase) ImportCSVStream(r io.Reader, opts ...CSVOption) (imported int64, err error) {
+	o := csvOptions{comma: ',', flushEvery: defaultCSVFlushEvery}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(db.fields) == 0 {
+		return 0, errors.New("xbase: ImportCSVStream: no fields")
+	}
+	if db.isAdd {
+		return 0, fmt.Errorf("xbase: ImportCSVStream: current record is in add mode, save it first")
+	}
+	cr := csv.NewReader(r)
+	cr.Comma = o.comma
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	streaming := db.streaming
+	db.streaming = true
+	defer func() {
+		db.streaming = streaming
+		if ferr := db.Flush(); err == nil {
+			err = ferr
+		}
+	}()
+
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return imported, nil
+		}
+		if err != nil {
+			return imported, fmt.Errorf("xbase: ImportCSVStream: %w", err)
+		}
+		if line == 1 && o.skipHeader {
+			continue
+		}
+		if err := db.importCSVRecord(rec); err != nil {
+			return imported, fmt.Errorf("xbase: ImportCSVStream: record %d: %w", line, err)
+		}
+		imported++
+		if o.flushEvery > 0 && imported%int64(o.flushEvery) == 0 {
+			if err := db.Flush(); err != nil {
+				return imported, err
+			}
+		}
+	}
+}
+
+// importCSVRecord appends the CSV record rec.
+func (db *XBase) importCSVRecord(rec []string) error {
+	if len(rec) != len(db.fields) {
+		return fmt.Errorf("%w: got %d values for %d fields", ErrFieldCount, len(rec), len(db.fields))
+	}
+	if err := db.Add(); err != nil {
+		return err
+	}
+	for i, s := range rec {
+		if s == "" {
+			continue
+		}
+		f := db.fields[i]
+		v, err := f.convert(s)
+		if err == nil {
+			err = db.setValue(i, v)
+		}
+		if err != nil {
+			db.isAdd = false
+			return fmt.Errorf("field %q: %w", f.name(), err)
+		}
+	}
+	return db.Save()
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/base64"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 )
 
 var (
@@ -61,6 +63,20 @@ func decodeFuncValuePtr(f reflect.Value) decodeFunc {
 	}
 }
 
+// decodeFuncReturning wraps f of type func(string) (T, error) into a
+// func([]byte, ptrType) error that stores the result through the pointer.
+func decodeFuncReturning(f reflect.Value, ptrType reflect.Type) reflect.Value {
+	fnType := reflect.FuncOf([]reflect.Type{_bytes, ptrType}, []reflect.Type{_error}, false)
+	return reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+		out := f.Call([]reflect.Value{reflect.ValueOf(string(args[0].Bytes()))})
+		if err, _ := out[1].Interface().(error); err != nil {
+			return []reflect.Value{out[1]}
+		}
+		args[1].Elem().Set(out[0])
+		return []reflect.Value{reflect.Zero(_error)}
+	})
+}
+
 func decodeString(s string, v reflect.Value) error {
 	v.SetString(s)
 	return nil
@@ -109,6 +125,9 @@ func decodeBool(s string, v reflect.Value) error {
 }
 
 func decodePtrTextUnmarshaler(s string, v reflect.Value) error {
+	if !v.CanAddr() {
+		return &UnmarshalTypeError{Value: s, Type: v.Type()}
+	}
 	return decodeTextUnmarshaler(s, v.Addr())
 }
 
@@ -117,9 +136,26 @@ func decodeTextUnmarshaler(s string, v reflect.Value) error {
 }
 
 func decodePtrFieldUnmarshaler(s string, v reflect.Value) error {
+	if !v.CanAddr() {
+		return &UnmarshalTypeError{Value: s, Type: v.Type()}
+	}
 	return decodeFieldUnmarshaler(s, v.Addr())
 }
 
+// decodeTime parses the "D" field layout, blank values are decoded to zero time.
+func decodeTime(s string, v reflect.Value) error {
+	if s == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	t, err := time.Parse("20060102", s)
+	if err != nil {
+		return &UnmarshalTypeError{Value: s, Type: v.Type()}
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
 func decodeFieldUnmarshaler(s string, v reflect.Value) error {
 	return v.Interface().(Unmarshaler).UnmarshalDBF([]byte(s))
 }
@@ -187,11 +223,22 @@ func decodeInterface(funcMap map[reflect.Type]reflect.Value, ifaceFuncs []reflec
 }
 
 func decodeBytes(s string, v reflect.Value) error {
-	b, err := base64.StdEncoding.DecodeString(s)
+	s = strings.TrimSpace(s)
+	if s == "" {
+		v.SetBytes(nil)
+		return nil
+	}
+	enc := base64.StdEncoding
+	if len(s)%4 != 0 {
+		// written without padding by other tools
+		enc = base64.RawStdEncoding
+	}
+	b := make([]byte, enc.DecodedLen(len(s)))
+	n, err := enc.Decode(b, []byte(s))
 	if err != nil {
-		return err
+		return &UnmarshalTypeError{Value: s, Type: v.Type()}
 	}
-	v.SetBytes(b)
+	v.SetBytes(b[:n])
 	return nil
 }
 
@@ -216,6 +263,10 @@ func decodeFn(typ reflect.Type, funcMap map[reflect.Type]reflect.Value, ifaceFun
 	if reflect.PtrTo(typ).Implements(dbfUnmarshaler) {
 		return decodePtrFieldUnmarshaler, nil
 	}
+	//time
+	if typ == timeType {
+		return decodeTime, nil
+	}
 	if reflect.PtrTo(typ).Implements(textUnmarshaler) {
 		return decodePtrTextUnmarshaler, nil
 	}
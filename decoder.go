@@ -25,6 +25,10 @@ type Decoder struct {
 	// provided struct.
 	DisallowMissingColumns bool
 
+	// If true, Decoder decodes all fields of a record even if some fail and
+	// returns a FieldErrors listing every failed field of the record.
+	AggregateFieldErrors bool
+
 	// If not nil, Map is a function that is called for each field in the dbf
 	// record before decoding the data. It allows mapping certain string values
 	// for specific columns or types to a known format. Decoder calls Map with
@@ -74,6 +78,9 @@ func NewDecoder(r Reader, fields ...string) (dec *Decoder, err error) {
 		if err != nil {
 			return nil, err
 		}
+	} else if hr, ok := r.(interface{ skipHeader() }); ok {
+		// the header is given, the first Read must return a record
+		hr.skipHeader()
 	}
 
 	h := make([]string, len(fields))
@@ -129,6 +136,10 @@ func NewDecoder(r Reader, fields ...string) (dec *Decoder, err error) {
 //	// Decode treats this field exactly as if it was an embedded field.
 //	Field Struct `dbf:",inline"`
 //
+//	// Decode matches this field with the third header column, whatever
+//	// its name is. Columns are numbered starting from 1.
+//	Field int `dbf:",index:3"`
+//
 // By default decode looks for "dbf" tag, but this can be changed by setting
 // Decoder.Tag field.
 //
@@ -189,6 +200,36 @@ func (d *Decoder) Decode(v interface{}) (err error) {
 	}
 }
 
+// DecodeMaps reads the input until EOF and stores every record in v as a map
+// keyed by the header columns, with trimmed string values. Character values
+// are decoded from the code page of the DBF file, the deletion flag is not
+// part of the values.
+//
+// DecodeMaps resets v. It returns io.EOF if there were no records.
+func (d *Decoder) DecodeMaps(v *[]map[string]string) (err error) {
+	*v = (*v)[:0]
+	for {
+		d.record, err = d.r.Read()
+		if err == io.EOF {
+			if len(*v) == 0 {
+				return io.EOF
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(d.record) != len(d.header) {
+			return ErrFieldCount
+		}
+		m := make(map[string]string, len(d.header))
+		for i, h := range d.header {
+			m[h] = d.record[i]
+		}
+		*v = append(*v, m)
+	}
+}
+
 // Record returns the most recently read record. The slice is valid until the
 // next call to Decode.
 func (d *Decoder) Record() []string {
@@ -203,6 +244,21 @@ func (d *Decoder) Header() []string {
 	return header
 }
 
+// WithManualHeader replaces the header with names, e.g. to decode a DBF
+// file whose field names are meaningless. The names are matched to the
+// columns of the records in order, so there must be one for every column.
+//
+// WithManualHeader must be called before Decode.
+func (d *Decoder) WithManualHeader(names []string) {
+	d.header = make([]string, len(names))
+	copy(d.header, names)
+	d.hmap = make(map[string]int, len(names))
+	for i, h := range names {
+		d.hmap[h] = i
+	}
+	d.cache, d.typeKey = nil, typeKey{}
+}
+
 // NormalizeHeader applies f to every column in the header. It returns error
 // if calling f results in conflicting header columns.
 //
@@ -239,9 +295,11 @@ func (d *Decoder) Unused() []int {
 // Register registers a custom decoding function for a concrete type or interface.
 // The argument f must be of type:
 // 	func([]byte, T) error
+// or
+// 	func(string) (T, error)
 //
 // T must be a concrete type such as *time.Time, or interface that has at least one
-// method.
+// method. With the second form T is matched exactly against the struct field type.
 //
 // During decoding, fields are matched by the concrete type first. If match is not
 // found then Decoder looks if field implements any of the registered interfaces
@@ -258,17 +316,28 @@ func (d *Decoder) Register(f interface{}) {
 	v := reflect.ValueOf(f)
 	typ := v.Type()
 
-	if typ.Kind() != reflect.Func ||
-		typ.NumIn() != 2 || typ.NumOut() != 1 ||
-		typ.In(0) != _inferface || typ.Out(0) != _error {
-		panic("xbase: func must be of type func([]byte, T) error")
+	var argType reflect.Type
+	switch {
+	case typ.Kind() != reflect.Func:
+	case typ.NumIn() == 2 && typ.NumOut() == 1 &&
+		typ.In(0) == _bytes && typ.Out(0) == _error:
+		argType = typ.In(1)
+	case typ.NumIn() == 1 && typ.NumOut() == 2 &&
+		typ.In(0) == _string && typ.Out(1) == _error:
+		// adapt to func([]byte, *T) error so it shares the pointer decode path.
+		argType = reflect.PtrTo(typ.Out(0))
+		v = decodeFuncReturning(v, argType)
+	}
+	if argType == nil {
+		panic("xbase: func must be of type func([]byte, T) error or func(string) (T, error)")
 	}
-
-	argType := typ.In(1)
 
 	if argType.Kind() == reflect.Interface && argType.NumMethod() == 0 {
 		panic("xbase: func argument type must not be an empty interface")
 	}
+	if argType.Kind() == reflect.Ptr && argType.Elem().Kind() == reflect.Interface && argType.Elem().NumMethod() == 0 {
+		panic("xbase: func result type must not be an empty interface")
+	}
 
 	if d.funcMap == nil {
 		d.funcMap = make(map[reflect.Type]reflect.Value)
@@ -362,6 +431,7 @@ func (d *Decoder) unmarshal(record []string, v reflect.Value) error {
 		return err
 	}
 
+	var errs FieldErrors
 fieldLoop:
 	for _, f := range fields {
 		isBlank := record[f.columnIndex] == ""
@@ -410,9 +480,16 @@ fieldLoop:
 		}
 
 		if err := f.decodeFunc(s, fv); err != nil {
-			return wrapDecodeError(d.r, d.header[f.columnIndex], f.columnIndex, err)
+			err = wrapDecodeError(d.r, d.header[f.columnIndex], f.columnIndex, err)
+			if !d.AggregateFieldErrors {
+				return err
+			}
+			errs = append(errs, err)
 		}
 	}
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
 
@@ -422,7 +499,7 @@ fieldLoop:
 // 	- column within record
 //
 // Line and Column info is available only if the used Reader supports 'FieldPos'
-// that is available e.g. in dbf.Reader (since Go1.17).
+// like XBase, where they are the record number and the field number.
 //
 // The caller should use errors.As in order to fetch the original error.
 func wrapDecodeError(r Reader, field string, fieldIndex int, err error) error {
@@ -459,6 +536,9 @@ func (d *Decoder) fields(k typeKey) ([]decField, error) {
 	)
 	for _, f := range fields {
 		i, ok := d.hmap[f.name]
+		if f.tag.column > 0 {
+			i, ok = f.tag.column-1, f.tag.column <= len(d.header)
+		}
 		if !ok {
 			if d.DisallowMissingColumns {
 				missingCols = append(missingCols, f.name)
@@ -0,0 +1,378 @@
+package xbase
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type level int
+
+const (
+	levelLow level = iota + 1
+	levelHigh
+)
+
+type LevelRec struct {
+	Name  string `dbf:"NAME,len:10"`
+	Level level  `dbf:"LEVEL,type:C,len:4"`
+}
+
+func encodeLevel(l level) (string, error) {
+	switch l {
+	case levelLow:
+		return "LOW", nil
+	case levelHigh:
+		return "HIGH", nil
+	}
+	return "", fmt.Errorf("unknown level %d", l)
+}
+
+func decodeLevel(s string) (level, error) {
+	switch s {
+	case "LOW":
+		return levelLow, nil
+	case "HIGH":
+		return levelHigh, nil
+	}
+	return 0, fmt.Errorf("unknown level %q", s)
+}
+
+func TestRegisterRoundTrip(t *testing.T) {
+	xb, err := New(NewSeekableBuffer())
+	require.NoError(t, err)
+	enc := NewEncoder(xb)
+	enc.Register(encodeLevel)
+	require.NoError(t, enc.Encode([]LevelRec{{Name: "a", Level: levelHigh}, {Name: "b", Level: levelLow}}))
+
+	require.NoError(t, xb.First())
+	assert.Equal(t, "HIGH", xb.FieldValueAsString(2))
+
+	dec, err := NewDecoder(xb, xb.Fields()...)
+	require.NoError(t, err)
+	dec.Register(decodeLevel)
+	var got LevelRec
+	require.NoError(t, dec.Decode(&got))
+	assert.Equal(t, LevelRec{Name: "a", Level: levelHigh}, got)
+}
+
+func TestRegisterBytesFunc(t *testing.T) {
+	xb, err := New(NewSeekableBuffer())
+	require.NoError(t, err)
+	enc := NewEncoder(xb)
+	enc.Register(encodeLevel)
+	require.NoError(t, enc.Encode([]LevelRec{{Name: "a", Level: levelLow}, {Name: "b", Level: levelHigh}}))
+
+	require.NoError(t, xb.First())
+	dec, err := NewDecoder(xb, xb.Fields()...)
+	require.NoError(t, err)
+	dec.Register(func(b []byte, l *level) error {
+		v, err := decodeLevel(string(b))
+		*l = v
+		return err
+	})
+	var got LevelRec
+	require.NoError(t, dec.Decode(&got))
+	assert.Equal(t, levelLow, got.Level)
+}
+
+func TestRegisterInvalidSignature(t *testing.T) {
+	assert.PanicsWithValue(t, "xbase: func must be of type func(T) (interface{}, error) or func(T) (string, error)", func() {
+		NewEncoder(nil).Register(func(l level) string { return "" })
+	})
+	assert.PanicsWithValue(t, "xbase: func must be of type func([]byte, T) error or func(string) (T, error)", func() {
+		dec := &Decoder{}
+		dec.Register(func(s string) level { return 0 })
+	})
+	assert.Panics(t, func() {
+		dec := &Decoder{}
+		dec.Register(decodeLevel)
+		dec.Register(decodeLevel)
+	})
+}
+
+type point struct {
+	X, Y int
+}
+
+func (p point) MarshalDBF() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d;%d", p.X, p.Y)), nil
+}
+
+func (p *point) UnmarshalDBF(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "%d;%d", &p.X, &p.Y)
+	return err
+}
+
+type PointRec struct {
+	Name string `dbf:"NAME,len:10"`
+	Pos  point  `dbf:"POS,type:C,len:12"`
+	Ptr  *point `dbf:"PTR,type:C,len:12"`
+}
+
+func TestUnmarshalerRoundTrip(t *testing.T) {
+	xb, err := New(NewSeekableBuffer())
+	require.NoError(t, err)
+	want := PointRec{Name: "a", Pos: point{1, -2}, Ptr: &point{30, 40}}
+	require.NoError(t, NewEncoder(xb).Encode([]PointRec{want, {Name: "b"}}))
+
+	require.NoError(t, xb.First())
+	assert.Equal(t, "1;-2", xb.FieldValueAsString(2))
+
+	var got PointRec
+	require.NoError(t, xb.DecodeRecord(&got))
+	assert.Equal(t, want, got)
+}
+
+type uid [4]byte
+
+func (u uid) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(u[:])), nil
+}
+
+func (u *uid) UnmarshalText(b []byte) error {
+	if len(b) != hex.EncodedLen(len(u)) {
+		return fmt.Errorf("invalid uid %q", b)
+	}
+	_, err := hex.Decode(u[:], b)
+	return err
+}
+
+type UIDRec struct {
+	ID   uid       `dbf:"ID,len:8"`
+	Ref  *uid      `dbf:"REF,len:8"`
+	Date time.Time `dbf:"DATE,type:D"`
+}
+
+func TestTextUnmarshalerRoundTrip(t *testing.T) {
+	xb, err := New(NewSeekableBuffer())
+	require.NoError(t, err)
+	want := UIDRec{
+		ID:   uid{0xde, 0xad, 0xbe, 0xef},
+		Ref:  &uid{0x01, 0x02, 0x03, 0x04},
+		Date: time.Date(2021, 2, 12, 0, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, NewEncoder(xb).Encode([]UIDRec{want, {}}))
+
+	require.NoError(t, xb.First())
+	assert.Equal(t, "deadbeef", xb.FieldValueAsString(1))
+
+	var got UIDRec
+	require.NoError(t, xb.DecodeRecord(&got))
+	assert.Equal(t, want, got)
+}
+
+func TestDecodeDate(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.First())
+	var got Rec
+	require.NoError(t, db.DecodeRecord(&got))
+	assert.Equal(t, time.Date(2021, 2, 12, 0, 0, 0, 0, time.UTC), got.Date)
+	assert.Equal(t, "Abc", got.Name)
+}
+
+func TestZeroDateRoundTrip(t *testing.T) {
+	xb, err := New(NewSeekableBuffer())
+	require.NoError(t, err)
+	require.NoError(t, NewEncoder(xb).Encode([]Rec{{Name: "a"}}))
+
+	require.NoError(t, xb.First())
+	dateNo := xb.FieldNo("DATE")
+	assert.Equal(t, "        ", string(xb.FieldValueAsBytes(dateNo)))
+	assert.True(t, xb.FieldValueAsDate(dateNo).IsZero())
+	require.NoError(t, xb.Error())
+
+	got := Rec{Date: time.Now()}
+	require.NoError(t, xb.DecodeRecord(&got))
+	assert.True(t, got.Date.IsZero())
+	assert.Equal(t, "a", got.Name)
+}
+
+type BytesRec struct {
+	Name string `dbf:"NAME,len:4"`
+	Data []byte `dbf:"DATA,len:20"`
+}
+
+func TestBytesRoundTrip(t *testing.T) {
+	tests := [][]byte{
+		{},
+		{0x01},
+		{0x01, 0x02},
+		{0x01, 0x02, 0x03},
+		{0x00, 0xff, 0x10, 0x20, 0x30},
+	}
+	xb, err := New(NewSeekableBuffer())
+	require.NoError(t, err)
+	for _, b := range tests {
+		require.NoError(t, xb.Append(&BytesRec{Name: "x", Data: b}))
+	}
+	require.NoError(t, xb.Append(&BytesRec{}))
+
+	require.NoError(t, xb.First())
+	for _, want := range tests {
+		var got BytesRec
+		require.NoError(t, xb.DecodeRecord(&got))
+		if len(want) == 0 {
+			assert.Empty(t, got.Data)
+			continue
+		}
+		assert.Equal(t, want, got.Data)
+	}
+}
+
+func TestDecodeBytesUnpadded(t *testing.T) {
+	var b []byte
+	v := reflect.ValueOf(&b).Elem()
+	require.NoError(t, decodeBytes("AQID", v))
+	assert.Equal(t, []byte{1, 2, 3}, b)
+	require.NoError(t, decodeBytes("AQI", v))
+	assert.Equal(t, []byte{1, 2}, b)
+	var ute *UnmarshalTypeError
+	assert.ErrorAs(t, decodeBytes("!!!!", v), &ute)
+}
+
+func TestDecodeMaps(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.First())
+	dec, err := NewDecoder(db, db.Fields()...)
+	require.NoError(t, err)
+
+	var got []map[string]string
+	require.NoError(t, dec.DecodeMaps(&got))
+	require.Len(t, got, 3)
+	assert.Equal(t, map[string]string{"NAME": "Abc", "FLAG": "T", "COUNT": "123", "PRICE": "123.45", "DATE": "20210212"}, got[0])
+	assert.Equal(t, "", got[1]["NAME"])
+	assert.Equal(t, "Мышь", got[2]["NAME"])
+	assert.Equal(t, "-321", got[2]["COUNT"])
+
+	assert.Equal(t, io.EOF, dec.DecodeMaps(&got))
+}
+
+func TestAggregateFieldErrors(t *testing.T) {
+	type textRec struct {
+		A string `dbf:"A,len:5"`
+		B string `dbf:"B,len:5"`
+		C string `dbf:"C,len:5"`
+	}
+	type intRec struct {
+		A int `dbf:"A"`
+		B int `dbf:"B"`
+		C int `dbf:"C"`
+	}
+	xb, err := New(NewSeekableBuffer())
+	require.NoError(t, err)
+	require.NoError(t, NewEncoder(xb).Encode([]textRec{{"x", "1", "y"}, {"x", "1", "y"}}))
+	require.NoError(t, xb.First())
+
+	dec, err := NewDecoder(xb, xb.Fields()...)
+	require.NoError(t, err)
+	var got intRec
+	err = dec.Decode(&got)
+	var fieldErrs FieldErrors
+	require.Error(t, err)
+	assert.False(t, errors.As(err, &fieldErrs))
+
+	dec.AggregateFieldErrors = true
+	err = dec.Decode(&got)
+	require.True(t, errors.As(err, &fieldErrs))
+	require.Len(t, fieldErrs, 2)
+	assert.Contains(t, fieldErrs[0].Error(), `field "A"`)
+	assert.Contains(t, fieldErrs[1].Error(), `field "C"`)
+	assert.Equal(t, 1, got.B)
+	assert.Contains(t, err.Error(), "xbase: 2 field errors: ")
+}
+
+func TestDecodeErrorPosition(t *testing.T) {
+	type textRec struct {
+		Name  string `dbf:"NAME,len:5"`
+		Count string `dbf:"COUNT,len:5"`
+	}
+	type intRec struct {
+		Name  string `dbf:"NAME"`
+		Count int    `dbf:"COUNT"`
+	}
+	xb, err := New(NewSeekableBuffer())
+	require.NoError(t, err)
+	require.NoError(t, NewEncoder(xb).Encode([]textRec{{"a", "1"}, {"b", "2"}, {"c", "x"}}))
+	require.NoError(t, xb.First())
+
+	dec, err := NewDecoder(xb, xb.Fields()...)
+	require.NoError(t, err)
+	var got []intRec
+	err = dec.Decode(&got)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `field "COUNT" record 3 column 2`)
+	var de *decodeError
+	require.True(t, errors.As(err, &de))
+	assert.Equal(t, 3, de.Line)
+	assert.Equal(t, 2, de.Column)
+}
+
+func TestDecodeByIndex(t *testing.T) {
+	db := MustNew(nil)
+	require.NoError(t, db.AddField("FIELD1", "C", 10))
+	require.NoError(t, db.AddField("FIELD2", "N", 5))
+	require.NoError(t, db.AddField("FIELD3", "C", 10))
+	require.NoError(t, db.CreateFile("./testdata/test-decode-index.dbf"))
+	defer db.Close()
+	require.NoError(t, db.Append(map[string]interface{}{"FIELD1": "Bob", "FIELD2": 42, "FIELD3": "Paris"}))
+	require.NoError(t, db.First())
+
+	type person struct {
+		Name string `dbf:",index:1"`
+		Age  int    `dbf:",index:2"`
+		City string `dbf:"CITY,index:3"`
+	}
+	dec, err := NewDecoder(db, db.Fields()...)
+	require.NoError(t, err)
+	var p person
+	require.NoError(t, dec.Decode(&p))
+	assert.Equal(t, person{Name: "Bob", Age: 42, City: "Paris"}, p)
+	assert.Nil(t, dec.Unused())
+
+	type missing struct {
+		Name string `dbf:",index:4"`
+	}
+	require.NoError(t, db.First())
+	dec, err = NewDecoder(db, db.Fields()...)
+	require.NoError(t, err)
+	dec.DisallowMissingColumns = true
+	var m missing
+	var mcErr *MissingColumnsError
+	assert.True(t, errors.As(dec.Decode(&m), &mcErr))
+}
+
+func TestDecodeManualHeader(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.First())
+	dec, err := NewDecoder(db, db.Fields()...)
+	require.NoError(t, err)
+	dec.WithManualHeader([]string{"Title", "Ok", "Qty", "Cost", "Day"})
+	assert.Equal(t, []string{"Title", "Ok", "Qty", "Cost", "Day"}, dec.Header())
+
+	type rec struct {
+		Title string
+		Qty   *int
+	}
+	var got []rec
+	require.NoError(t, dec.Decode(&got))
+	require.Len(t, got, 3)
+	assert.Equal(t, "Abc", got[0].Title)
+	require.NotNil(t, got[0].Qty)
+	assert.Equal(t, 123, *got[0].Qty)
+	assert.Nil(t, got[1].Qty)
+	assert.Equal(t, []int{1, 3, 4}, dec.Unused())
+}
@@ -27,7 +27,7 @@ func encodeFuncValue(fn reflect.Value) encodeFunc {
 		if err != nil {
 			return nil, err
 		}
-		return out, nil
+		return out[0].Interface(), nil
 	}
 }
 
@@ -46,7 +46,7 @@ func encodeFuncValuePtr(fn reflect.Value) encodeFunc {
 		if err != nil {
 			return nil, err
 		}
-		return out, nil
+		return out[0].Interface(), nil
 	}
 }
 
@@ -118,7 +118,7 @@ func encodeMarshaler(v reflect.Value, _ bool) (interface{}, error) {
 
 	b, err := v.Interface().(Marshaler).MarshalDBF()
 	if err != nil {
-		return nil, &MarshalerError{Type: v.Type(), MarshalerType: "MarshalCSV", Err: err}
+		return nil, &MarshalerError{Type: v.Type(), MarshalerType: "MarshalDBF", Err: err}
 	}
 	return b, nil
 }
@@ -143,6 +143,25 @@ func encodeBytes(v reflect.Value, _ bool) (interface{}, error) {
 	return buf, nil
 }
 
+// customEncoded reports whether values of typ are encoded by a registered func
+// or a marshaler rather than by their kind.
+func customEncoded(typ reflect.Type, funcMap map[reflect.Type]reflect.Value, funcs []reflect.Value) bool {
+	ptr := reflect.PtrTo(typ)
+	if _, ok := funcMap[typ]; ok {
+		return true
+	}
+	if _, ok := funcMap[ptr]; ok {
+		return true
+	}
+	for _, v := range funcs {
+		argType := v.Type().In(0)
+		if typ.AssignableTo(argType) || ptr.AssignableTo(argType) {
+			return true
+		}
+	}
+	return isTextType(typ)
+}
+
 func encodeFn(typ reflect.Type, canAddr bool, funcMap map[reflect.Type]reflect.Value, funcs []reflect.Value) (encodeFunc, error) {
 	if v, ok := funcMap[typ]; ok {
 		return encodeFuncValue(v), nil
@@ -172,7 +191,7 @@ func encodeFn(typ reflect.Type, canAddr bool, funcMap map[reflect.Type]reflect.V
 	}
 
 	//time
-	if typ.String() == "time.Time" {
+	if typ == timeType {
 		return nopEncode, nil
 	}
 
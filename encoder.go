@@ -2,6 +2,7 @@ package xbase
 
 import (
 	"bytes"
+	"fmt"
 	"reflect"
 	"sort"
 )
@@ -18,15 +19,24 @@ type encCache struct {
 	fields []encField
 }
 
-func newEncCache(k typeKey, funcMap map[reflect.Type]reflect.Value, funcs []reflect.Value) (_ *encCache, err error) {
+func newEncCache(k typeKey, funcMap map[reflect.Type]reflect.Value, funcs []reflect.Value, lens map[string]int) (_ *encCache, err error) {
 	fields := cachedFields(k)
 	encFields := make([]encField, 0, len(fields))
 
 	for _, f := range fields {
-		fm, err := NewField(f.name, f.tag.dbfType, f.tag.length, f.tag.decimal)
+		length, dec := f.tag.fieldLen()
+		if n, ok := lens[f.name]; ok && f.tag.length == 0 {
+			length = n
+		}
+		fm, err := NewField(f.name, f.tag.dbfType, length, dec)
 		if err != nil {
 			return nil, err
 		}
+		if !customEncoded(walkType(f.baseType), funcMap, funcs) {
+			if err := checkTagType(f); err != nil {
+				return nil, err
+			}
+		}
 		fn, err := encodeFn(f.baseType, true, funcMap, funcs)
 		if err != nil {
 			return nil, err
@@ -75,6 +85,10 @@ type Encoder struct {
 	typeKey    typeKey
 	funcMap    map[reflect.Type]reflect.Value
 	ifaceFuncs []reflect.Value
+	// autoLen holds measured lengths of fields without len tag
+	autoLen map[string]int
+	// err is the first error returned by Encode
+	err error
 }
 
 // NewEncoder returns a new encoder that writes to w.
@@ -89,6 +103,8 @@ func NewEncoder(w Writer) *Encoder {
 // Register registers a custom encoding function for a concrete type or interface.
 // The argument f must be of type:
 // 	func(T) (interface{}, error)
+// or
+// 	func(T) (string, error)
 //
 // T must be a concrete type such as Foo or *Foo, or interface that has at
 // least one method.
@@ -110,8 +126,8 @@ func (e *Encoder) Register(f interface{}) {
 
 	if typ.Kind() != reflect.Func ||
 		typ.NumIn() != 1 || typ.NumOut() != 2 ||
-		typ.Out(0) != _inferface || typ.Out(1) != _error {
-		panic("xbase: func must be of type func(T) (interface{}, error)")
+		(typ.Out(0) != _inferface && typ.Out(0) != _string) || typ.Out(1) != _error {
+		panic("xbase: func must be of type func(T) (interface{}, error) or func(T) (string, error)")
 	}
 
 	argType := typ.In(0)
@@ -212,6 +228,10 @@ func (enc *Encoder) SetHeader(header []*field) {
 // 	// Encode ignores this field.
 // 	Field int `dbf:"-"`
 //
+//	// Field is written padded with zeros, e.g. "00042" in a field of
+//	// length 5, if the Writer is an XBase.
+//	Field int `dbf:",len:5,zeropad"`
+//
 //	// Encode treats this field exactly as if it was an embedded field and adds
 //	// "my_prefix_" to each field's name.
 //	Field Struct `dbf:"my_prefix_,inline"`
@@ -222,10 +242,35 @@ func (enc *Encoder) SetHeader(header []*field) {
 // Fields with inline tags that have a non-empty prefix must not be cyclic
 // structures. Passing such values to Encode will result in an infinite loop.
 //
-// Encode doesn't flush data. The caller is responsible for calling Flush() if
-// the used Writer supports it.
+// Encode doesn't flush data. The caller is responsible for calling Flush.
+//
+// If v is a slice or an array, Encode stops at the first element that fails
+// and the error names its index. The first error is also kept and returned
+// by Error and Flush.
 func (e *Encoder) Encode(v interface{}) error {
-	return e.encode(reflect.ValueOf(v))
+	err := e.encode(reflect.ValueOf(v))
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+	return err
+}
+
+// Error returns the first error returned by Encode, if any.
+func (e *Encoder) Error() error {
+	return e.err
+}
+
+// Flush flushes the Writer if it has a Flush method, like XBase, and returns
+// the first error returned by Encode, otherwise the error of the flush.
+func (e *Encoder) Flush() error {
+	var err error
+	if f, ok := e.w.(interface{ Flush() error }); ok {
+		err = f.Flush()
+	}
+	if e.err != nil {
+		return e.err
+	}
+	return err
 }
 
 // EncodeHeader writes the DBF header of the provided struct value to the output
@@ -246,6 +291,34 @@ func (e *Encoder) EncodeHeader(v interface{}) error {
 	return e.encodeHeader(typ)
 }
 
+// EncodeHeaderOnly writes the DBF header and field descriptors derived from
+// the struct type of v without any record, so that a table can be created
+// first and records appended later by Encode. It flushes the Writer if it
+// has a Flush method.
+//
+// It does nothing if the header was already written by the Encoder, or if
+// the Writer is an XBase which already has fields.
+func (e *Encoder) EncodeHeaderOnly(v interface{}) error {
+	typ, err := valueType(v)
+	if err != nil {
+		return err
+	}
+	if !e.noHeader {
+		return nil
+	}
+	if db, ok := e.w.(*XBase); ok && len(db.fields) > 0 {
+		e.SetHeader(db.fields)
+		return nil
+	}
+	if err := e.encodeHeader(typ); err != nil {
+		return err
+	}
+	if f, ok := e.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
 func (e *Encoder) encode(v reflect.Value) error {
 	val := walkValue(v)
 
@@ -279,7 +352,7 @@ func (e *Encoder) encodeArray(v reflect.Value) error {
 	l := v.Len()
 	for i := 0; i < l; i++ {
 		if err := e.encodeStruct(walkValue(v.Index(i))); err != nil {
-			return err
+			return fmt.Errorf("xbase: Encode: element %d: %w", i, err)
 		}
 	}
 	return nil
@@ -293,19 +366,21 @@ func (e *Encoder) encodeHeader(typ reflect.Type) error {
 
 	var bf = new(bytes.Buffer)
 	for _, f := range fields {
-		fm, err := NewField(f.name, f.fieldDescription.tag.dbfType, f.fieldDescription.tag.length, f.fieldDescription.tag.decimal)
-		if err != nil {
-			return err
-		}
-		if err := fm.write(bf); err != nil {
+		if err := f.field.write(bf); err != nil {
 			return err
 		}
-		f.field = fm
 	}
 
 	if err := e.w.Write([]interface{}{len(fields), bf.Bytes()}); err != nil {
 		return err
 	}
+	if zp, ok := e.w.(interface{ SetFieldZeroPad(int, bool) }); ok {
+		for i, f := range fields {
+			if f.tag.zeroPad {
+				zp.SetFieldZeroPad(i+1, true)
+			}
+		}
+	}
 
 	e.noHeader = false
 	return nil
@@ -373,7 +448,7 @@ func (e *Encoder) tag() string {
 
 func (e *Encoder) cache(typ reflect.Type) ([]encField, error) {
 	if k := (typeKey{e.tag(), typ}); k != e.typeKey {
-		c, err := newEncCache(k, e.funcMap, e.ifaceFuncs)
+		c, err := newEncCache(k, e.funcMap, e.ifaceFuncs, e.autoLen)
 		if err != nil {
 			return nil, err
 		}
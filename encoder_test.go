@@ -1,6 +1,7 @@
 package xbase
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"os"
@@ -133,3 +134,243 @@ func TestNewEncoderRec(t *testing.T) {
 		})
 	}
 }
+
+func TestEncoderTagTypeMismatch(t *testing.T) {
+	type badRec struct {
+		Amount string `dbf:"AMOUNT,type:N,len:10"`
+	}
+	xb, err := New(NewSeekableBuffer())
+	assert.NoError(t, err)
+	err = NewEncoder(xb).EncodeHeader(badRec{})
+	var tte *TagTypeError
+	if assert.ErrorAs(t, err, &tte) {
+		assert.Equal(t, "Amount", tte.Field)
+		assert.Equal(t, "N", tte.DBFType)
+	}
+	assert.EqualError(t, err, `xbase: struct field Amount of type string can't be encoded as dbf type "N"`)
+
+	type okRec struct {
+		Level level     `dbf:"LEVEL,type:C,len:4"`
+		When  time.Time `dbf:"WHEN,type:D"`
+		Count *int      `dbf:"COUNT,type:N,len:4"`
+	}
+	assert.NoError(t, NewEncoder(xb).EncodeHeader(okRec{}))
+}
+
+func TestEncoderTagTypeMismatches(t *testing.T) {
+	type numString struct {
+		V string `dbf:"V,type:N,len:5"`
+	}
+	type dateBool struct {
+		V bool `dbf:"V,type:D"`
+	}
+	type logicalInt struct {
+		V int `dbf:"V,type:L"`
+	}
+	type floatTime struct {
+		V time.Time `dbf:"V,type:F,len:8,dec:2"`
+	}
+	type autoIncFloat struct {
+		V float64 `dbf:"V,type:+"`
+	}
+	type binaryString struct {
+		V *string `dbf:"V,type:B"`
+	}
+	for _, tt := range []struct {
+		v       interface{}
+		dbfType string
+	}{
+		{[]numString{{"1"}}, "N"},
+		{[]dateBool{{true}}, "D"},
+		{[]logicalInt{{1}}, "L"},
+		{[]floatTime{{time.Now()}}, "F"},
+		{[]autoIncFloat{{1}}, "+"},
+		{[]binaryString{{nil}}, "B"},
+	} {
+		xb, err := New(NewSeekableBuffer())
+		assert.NoError(t, err)
+		err = NewEncoder(xb).Encode(tt.v)
+		var tte *TagTypeError
+		if assert.ErrorAs(t, err, &tte, "%T", tt.v) {
+			assert.Equal(t, "V", tte.Field)
+			assert.Equal(t, tt.dbfType, tte.DBFType)
+		}
+		assert.Empty(t, xb.Fields(), "%T", tt.v)
+		assert.Equal(t, int64(0), xb.RecCount(), "%T", tt.v)
+	}
+}
+
+func TestMarshalAutoSize(t *testing.T) {
+	type autoRec struct {
+		Name  string `dbf:"NAME"`
+		Note  string `dbf:"NOTE,len:10"`
+		Count int    `dbf:"COUNT,len:3"`
+	}
+	b, err := MarshalAutoSize([]*autoRec{{Name: "a", Count: 1}, nil, {Name: "abcdef", Note: "x", Count: 2}})
+	assert.NoError(t, err)
+
+	xb, err := New(NewSeekableBufferWithBytes(b))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), xb.RecCount())
+	assert.Equal(t, byte(6), xb.fields[0].Len)
+	assert.Equal(t, byte(10), xb.fields[1].Len)
+	assert.NoError(t, xb.Last())
+	assert.Equal(t, "abcdef", xb.FieldValueAsString(1))
+
+	_, err = MarshalAutoSize([]int{1})
+	var ime *InvalidMarshalError
+	assert.ErrorAs(t, err, &ime)
+}
+
+func TestFieldSpecs(t *testing.T) {
+	type specRec struct {
+		Name    string    `dbf:"NAME,len:20"`
+		Code    string    `dbf:"CODE"`
+		Count   int       `dbf:"COUNT"`
+		Price   float64   `dbf:"PRICE"`
+		Rate    float64   `dbf:"RATE,type:F,len:6,dec:3"`
+		Flag    bool      `dbf:"FLAG"`
+		Date    time.Time `dbf:"DATE"`
+		Skipped string    `dbf:"-"`
+	}
+	specs, err := FieldSpecs([]specRec{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []FieldSpec{
+		{Name: "NAME", Type: "C", Len: 20},
+		{Name: "CODE", Type: "C", Len: 254},
+		{Name: "COUNT", Type: "N", Len: 10},
+		{Name: "PRICE", Type: "F", Len: 12, Dec: 2},
+		{Name: "RATE", Type: "F", Len: 6, Dec: 3},
+		{Name: "FLAG", Type: "L", Len: 1},
+		{Name: "DATE", Type: "D", Len: 8},
+	}, specs)
+	xb := MustNew(nil)
+	for _, spec := range specs {
+		assert.NoError(t, xb.AddField(spec.Name, spec.Type, spec.Len, spec.Dec))
+	}
+	assert.Equal(t, []string{"NAME", "CODE", "COUNT", "PRICE", "RATE", "FLAG", "DATE"}, xb.Fields())
+
+	_, err = FieldSpecs(1, "")
+	var ime *InvalidMarshalError
+	assert.ErrorAs(t, err, &ime)
+
+	type badRec struct {
+		Flag string `dbf:"FLAG,type:L"`
+	}
+	_, err = FieldSpecs(&badRec{}, "")
+	var tte *TagTypeError
+	assert.ErrorAs(t, err, &tte)
+}
+
+func TestEncodeHeaderOnly(t *testing.T) {
+	type rec struct {
+		Name  string `dbf:"NAME,len:10"`
+		Count int    `dbf:"COUNT,len:4"`
+	}
+	buf := NewSeekableBuffer()
+	xb, err := New(buf)
+	assert.NoError(t, err)
+	enc := NewEncoder(xb)
+	assert.NoError(t, enc.EncodeHeaderOnly(rec{}))
+	assert.NoError(t, enc.EncodeHeaderOnly(rec{}))
+	assert.Equal(t, int64(0), xb.RecCount())
+	assert.Equal(t, []string{"NAME", "COUNT"}, xb.Fields())
+	size := len(buf.Bytes())
+	assert.Equal(t, headerSize+2*fieldSize+2, size)
+	assert.Equal(t, fileEnd, buf.Bytes()[size-1])
+
+	// a new encoder doesn't write the header again
+	enc = NewEncoder(xb)
+	assert.NoError(t, enc.EncodeHeaderOnly(rec{}))
+	assert.NoError(t, enc.Encode([]rec{{"a", 1}, {"b", 2}}))
+	assert.NoError(t, enc.Encode(rec{"c", 3}))
+	assert.Equal(t, int64(3), xb.RecCount())
+	assert.Empty(t, xb.Validate())
+	assert.NoError(t, xb.Last())
+	assert.Equal(t, "c", xb.FieldValueAsString(1))
+}
+
+func TestEncodeNestedInlinePrefix(t *testing.T) {
+	type inner struct {
+		X string `dbf:"X,len:3"`
+	}
+	type middle struct {
+		In inner `dbf:"B_,inline"`
+		Y  int   `dbf:"Y,len:3"`
+	}
+	type outer struct {
+		Mid middle `dbf:"A_,inline"`
+		Z   string `dbf:"Z,len:3"`
+	}
+	xb, err := New(NewSeekableBuffer())
+	assert.NoError(t, err)
+	want := outer{Mid: middle{In: inner{X: "x"}, Y: 1}, Z: "z"}
+	assert.NoError(t, NewEncoder(xb).Encode([]outer{want}))
+	assert.Equal(t, []string{"A_B_X", "A_Y", "Z"}, xb.Fields())
+
+	assert.NoError(t, xb.First())
+	var got outer
+	assert.NoError(t, xb.DecodeRecord(&got))
+	assert.Equal(t, want, got)
+}
+
+func TestEncodeZeroPad(t *testing.T) {
+	type rec struct {
+		Key   int     `dbf:"KEY,len:5,zeropad"`
+		Neg   int     `dbf:"NEG,len:5,zeropad"`
+		Price float64 `dbf:"PRICE,type:F,len:7,dec:2,zeropad"`
+		Count int     `dbf:"COUNT,len:5"`
+	}
+	xb, err := New(NewSeekableBuffer())
+	assert.NoError(t, err)
+	assert.NoError(t, NewEncoder(xb).Encode([]rec{{Key: 42, Neg: -42, Price: 1.5, Count: 42}}))
+
+	assert.NoError(t, xb.First())
+	b := xb.RecordBytes()
+	assert.Equal(t, " 00042-00420001.50   42", string(b))
+	var got rec
+	assert.NoError(t, xb.DecodeRecord(&got))
+	assert.Equal(t, rec{Key: 42, Neg: -42, Price: 1.5, Count: 42}, got)
+}
+
+func TestEncoderError(t *testing.T) {
+	type rec struct {
+		Name  string `dbf:"NAME,len:5"`
+		Count int    `dbf:"COUNT,len:4"`
+	}
+	xb, err := New(NewSeekableBuffer())
+	assert.NoError(t, err)
+	enc := NewEncoder(xb)
+	assert.NoError(t, enc.Encode(rec{"a", 1}))
+	assert.NoError(t, enc.Error())
+
+	err = enc.Encode([]rec{{"b", 2}, {"too long", 3}, {"d", 4}})
+	assert.EqualError(t, err, `xbase: Encode: element 1: field "NAME" value overflow: "too long" has len 8, field len 5`)
+	var overflow *FieldOverflowError
+	assert.True(t, errors.As(err, &overflow))
+	assert.Equal(t, "NAME", overflow.Field)
+	assert.Equal(t, err, enc.Error())
+
+	// later encodes work, the first error is kept
+	assert.NoError(t, enc.Encode(rec{"e", 5}))
+	assert.Equal(t, err, enc.Flush())
+	assert.Equal(t, int64(3), xb.RecCount())
+}
+
+func TestEncoderRegister(t *testing.T) {
+	encodeAny := func(l level) (interface{}, error) {
+		return encodeLevel(l)
+	}
+	for _, fn := range []interface{}{encodeLevel, encodeAny} {
+		xb, err := New(NewSeekableBuffer())
+		assert.NoError(t, err)
+		enc := NewEncoder(xb)
+		assert.NotPanics(t, func() { enc.Register(fn) })
+		assert.NoError(t, enc.Encode([]LevelRec{{Name: "a", Level: levelHigh}, {Name: "b", Level: levelLow}}))
+
+		assert.NoError(t, xb.First())
+		assert.Equal(t, "HIGH", xb.FieldValueAsString(2))
+		assert.NoError(t, xb.Next())
+		assert.Equal(t, "LOW", xb.FieldValueAsString(2))
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 )
 
 // ErrFieldCount is returned when header's length doesn't match the length of
@@ -14,6 +15,22 @@ var ErrFieldCount = errors.New("wrong number of fields in record")
 
 var BOF = errors.New("BOF")
 
+// ErrReadOnly is returned when writing to a DBF opened read-only.
+var ErrReadOnly = errors.New("xbase: file is read-only")
+
+// ErrTruncatedRecord is returned when a record buffer is shorter than the
+// field layout, e.g. the record size in the header is too small.
+var ErrTruncatedRecord = errors.New("xbase: record is shorter than the field layout")
+
+// ErrIndexNotMaintained is reported by Warnings when a table with a
+// production index (.mdx) is opened read-write: the index isn't updated
+// when records are changed and becomes stale.
+var ErrIndexNotMaintained = errors.New("xbase: production index is not maintained, changes make it stale")
+
+// ErrNumericOverflow is returned when reading a numeric value filled with
+// asterisks, which dBase writes when a value doesn't fit the field.
+var ErrNumericOverflow = errors.New("xbase: numeric overflow")
+
 // An UnmarshalTypeError describes a string value that was not appropriate for
 // a value of a specific Go type.
 type UnmarshalTypeError struct {
@@ -85,6 +102,19 @@ func (e *InvalidUnmarshalError) Error() string {
 	return "xbase: Unmarshal(invalid type " + e.Type.String() + ")"
 }
 
+// A TagTypeError is returned by Encoder when the dbf type declared in a struct
+// tag can't hold values of the Go type of the field. It's returned when the
+// header is encoded, before any record is written.
+type TagTypeError struct {
+	Field   string       // name of the struct field
+	Type    reflect.Type // Go type of the struct field
+	DBFType string       // dbf type from the tag
+}
+
+func (e *TagTypeError) Error() string {
+	return "xbase: struct field " + e.Field + " of type " + e.Type.String() + " can't be encoded as dbf type " + strconv.Quote(e.DBFType)
+}
+
 // InvalidEncodeError is returned by Encode when the provided value was invalid.
 type InvalidEncodeError struct {
 	Type reflect.Type
@@ -157,6 +187,39 @@ func (e *MissingColumnsError) Error() string {
 	return b.String()
 }
 
+// FieldErrors is returned by Decoder when AggregateFieldErrors option was set
+// to true. It contains the errors of all fields of a record that failed to
+// decode.
+type FieldErrors []error
+
+func (e FieldErrors) Error() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "xbase: %d field errors: ", len(e))
+	for i, err := range e {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// A FieldOverflowError is returned when a formatted value is longer than the
+// field.
+type FieldOverflowError struct {
+	Field string // field name
+	Value string // formatted value
+	Len   int    // field length
+}
+
+func (e *FieldOverflowError) Error() string {
+	msg := fmt.Sprintf("field %q value overflow: %q has len %d, field len %d", e.Field, e.Value, len(e.Value), e.Len)
+	if strings.HasPrefix(e.Value, "-") && len(e.Value)-1 <= e.Len {
+		msg += " (the minus sign takes one position)"
+	}
+	return msg
+}
+
 // decodeError provides context to decoding errors if available.
 //
 // The caller should use errors.As in order to fetch the underlying error if
@@ -171,7 +234,8 @@ type decodeError struct {
 func (e *decodeError) Error() string {
 	if e.Line > 0 && e.Column > 0 {
 		// Lines and Columns are 1-indexed so this check is fine.
-		return fmt.Sprintf("%s: field %q line %d column %d", e.Err, e.Field, e.Line, e.Column)
+		// For XBase they are the record and the field number.
+		return fmt.Sprintf("%s: field %q record %d column %d", e.Err, e.Field, e.Line, e.Column)
 	}
 	return fmt.Sprintf("%s: field %q", e.Err, e.Field)
 }
@@ -0,0 +1,33 @@
+package xbase_test
+
+import (
+	"fmt"
+
+	"github.com/tsingsun/xbase"
+)
+
+func ExampleMustNew() {
+	type Rec struct {
+		Name  string `dbf:"NAME,len:10"`
+		Count int    `dbf:"COUNT,len:5"`
+	}
+
+	buf := xbase.NewSeekableBuffer()
+	db := xbase.MustNew(buf)
+	db.Append(&Rec{Name: "Abc", Count: 1})
+	db.Append(&Rec{Name: "Def", Count: 2})
+	if err := db.Close(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	db = xbase.MustNew(xbase.NewSeekableBufferWithBytes(buf.Bytes()))
+	fmt.Println(db.Fields(), db.RecCount())
+	for err := db.First(); err == nil; err = db.Next() {
+		fmt.Println(db.FieldValueAsString(1), db.FieldValueAsInt(2))
+	}
+	// Output:
+	// [NAME COUNT] 2
+	// Abc 1
+	// Def 2
+}
@@ -5,25 +5,42 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
 	"strings"
 	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"golang.org/x/text/encoding"
 )
 
 const (
 	maxFieldNameLen = 10
-	maxCFieldLen    = 254
-	maxNFieldLen    = 19
+	// dBase 7 field names
+	maxLongFieldNameLen = 32
+	maxCFieldLen        = 254
+	maxNFieldLen        = 19
 )
 
 const (
 	defaultLFieldLen = 1
 	defaultDFieldLen = 8
+	// autoincrement is a 4 byte long
+	defaultAutoIncFieldLen = 4
+	// binary is an 8 byte double
+	defaultBFieldLen = 8
+	// used if the length is omitted
+	defaultCFieldLen = maxCFieldLen
+	defaultNFieldLen = 10
+	defaultFFieldLen = 12
+	defaultFFieldDec = 2
 )
 
+// autoIncNextOffset is the position of the next autoincrement value in Filler.
+// It is stored in the bytes 19-22 of the field descriptor.
+const autoIncNextOffset = 1
+
 // https://www.dbase.com/Knowledgebase/INT/db7_file_fmt.htm
 // http://www.dbase.com/help/Design_Tables/IDH_TABLEDES_FIELD_TYPES.htm
 const (
@@ -42,7 +59,68 @@ const (
 	FieldType_Autoincrement = '+'
 )
 
+// knownFieldTypes lists the field types of the dBase family.
+const knownFieldTypes = "CNDFLBM@IOG+"
+
+// fieldTypeNames are the names of the known field types.
+var fieldTypeNames = map[byte]string{
+	FieldType_Character:     "Character",
+	FieldType_Numeric:       "Numeric",
+	FieldType_Date:          "Date",
+	FieldType_Float:         "Float",
+	FieldType_Logical:       "Logical",
+	FieldType_Binary:        "Binary",
+	FieldType_Memo:          "Memo",
+	FieldType_Timestamp:     "Timestamp",
+	FieldType_Long:          "Long",
+	FieldType_Double:        "Double",
+	FieldType_OLE:           "General",
+	FieldType_Autoincrement: "Autoincrement",
+}
+
+// FieldTypeName returns the name of the field type t, e.g. "Numeric" for 'N',
+// or "Unknown" if t isn't a known type.
+func FieldTypeName(t byte) string {
+	if name, ok := fieldTypeNames[t]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// ParseFieldType returns the field type for s, either its letter or its name
+// case-insensitively, e.g. "N", "n" and "numeric". The known types are
+// Character (C), Numeric (N), Date (D), Float (F), Logical (L), Binary (B),
+// Memo (M), Timestamp (@), Long (I), Double (O), General (G) and
+// Autoincrement (+).
+func ParseFieldType(s string) (byte, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 1 {
+		t := strings.ToUpper(s)[0]
+		if strings.IndexByte(knownFieldTypes, t) >= 0 {
+			return t, nil
+		}
+	}
+	for t, name := range fieldTypeNames {
+		if strings.EqualFold(s, name) {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown field type %q", s)
+}
+
 type field struct {
+	Name   [maxLongFieldNameLen]byte
+	Type   byte
+	Offset uint32
+	Len    byte
+	Dec    byte
+	Filler [14]byte
+	// zeroPadded pads written numeric values with zeros instead of spaces
+	zeroPadded bool
+}
+
+// fieldDesc is the field descriptor of dBase III and IV files.
+type fieldDesc struct {
 	Name   [11]byte
 	Type   byte
 	Offset uint32
@@ -51,8 +129,66 @@ type field struct {
 	Filler [14]byte
 }
 
+// fieldDesc7 is the field descriptor of dBase 7 files. Its MDX flag and next
+// autoincrement value are kept in field.Filler at the dBase III positions.
+type fieldDesc7 struct {
+	Name        [maxLongFieldNameLen]byte
+	Type        byte
+	Len         byte
+	Dec         byte
+	Reserved1   [2]byte
+	MDX         byte
+	Reserved2   [2]byte
+	AutoIncNext [4]byte
+	Reserved3   [4]byte
+}
+
+// mdxFlagOffset is the position of the production index flag in Filler.
+const mdxFlagOffset = 13
+
+// FieldInfo describes a field of a DBF file.
+type FieldInfo struct {
+	Name string
+	Type byte
+	Len  int
+	Dec  int
+}
+
+// FieldSpec is the definition of a field to create, its members are the
+// arguments of AddField.
+type FieldSpec struct {
+	Name string
+	Type string
+	Len  int
+	Dec  int
+}
+
+// Column describes a field of a DBF file and its place in the record.
+type Column struct {
+	Name string
+	Type byte
+	Len  int
+	Dec  int
+	// Offset is the position of the field in the record,
+	// the deletion flag is at offset 0.
+	Offset int
+	// Ordinal is the field number, starting from 1.
+	Ordinal int
+}
+
+func (f *field) info() FieldInfo {
+	return FieldInfo{Name: f.name(), Type: f.Type, Len: int(f.Len), Dec: int(f.Dec)}
+}
+
+func (f *field) spec() FieldSpec {
+	return FieldSpec{Name: f.name(), Type: string(f.Type), Len: int(f.Len), Dec: int(f.Dec)}
+}
+
 func (f *field) name() string {
 	i := bytes.IndexByte(f.Name[:], 0)
+	if i < 0 {
+		i = len(f.Name)
+	}
 	return string(f.Name[:i])
 }
 
@@ -72,6 +208,22 @@ func padLeft(s string, width int) string {
 	return strings.Repeat(" ", width-len(s)) + s
 }
 
+// isBlank reports whether b holds only spaces or zero bytes.
+func isBlank(b []byte) bool {
+	for _, c := range b {
+		if c != ' ' && c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isOverflow reports whether the trimmed numeric value s is filled with
+// asterisks, which dBase writes when a value doesn't fit the field.
+func isOverflow(s string) bool {
+	return s != "" && strings.Trim(s, "*") == ""
+}
+
 func isASCII(s string) bool {
 	for i := 0; i < len(s); i++ {
 		if s[i] > unicode.MaxASCII {
@@ -83,14 +235,22 @@ func isASCII(s string) bool {
 
 // NewField return dbf field description
 func NewField(name string, typ string, length, dec int) (f *field, err error) {
+	return newField(name, typ, length, dec, maxFieldNameLen)
+}
+
+// newField is like NewField but allows names up to maxNameLen.
+func newField(name string, typ string, length, dec, maxNameLen int) (f *field, err error) {
 	f = &field{}
 	// do not change the call order
-	if err = f.setName(name); err != nil {
+	if err = f.setNameLen(name, maxNameLen); err != nil {
 		return
 	}
 	if err = f.setType(typ); err != nil {
 		return
 	}
+	if length == 0 {
+		length, dec = defaultFieldLen(f.Type, dec)
+	}
 	if err = f.setLen(length); err != nil {
 		return
 	}
@@ -101,30 +261,68 @@ func NewField(name string, typ string, length, dec int) (f *field, err error) {
 }
 
 func (f *field) setName(name string) error {
-	name = strings.ToUpper(strings.TrimSpace(name))
+	return f.setNameLen(name, maxFieldNameLen)
+}
+
+func (f *field) setNameLen(name string, maxLen int) error {
+	return f.setNameCase(strings.ToUpper(name), maxLen)
+}
+
+// setNameCase is like setNameLen but keeps the case of name.
+func (f *field) setNameCase(name string, maxLen int) error {
+	name = strings.TrimSpace(name)
 	if len(name) == 0 {
 		return fmt.Errorf("empty field name")
 	}
-	if len(name) > maxFieldNameLen {
-		return fmt.Errorf("too long field name: %q, max len %d", name, maxFieldNameLen)
+	if len(name) > maxLen {
+		return fmt.Errorf("too long field name: %q, max len %d", name, maxLen)
 	}
+	f.Name = [maxLongFieldNameLen]byte{}
 	copy(f.Name[:], name)
 	return nil
 }
 
 func (f *field) setType(typ string) error {
-	typ = strings.ToUpper(strings.TrimSpace(typ))
-	if len(typ) == 0 {
+	if len(strings.TrimSpace(typ)) == 0 {
 		return fmt.Errorf("empty field type")
 	}
-	t := typ[0]
-	if bytes.IndexByte([]byte("CNLDF"), t) < 0 {
-		return fmt.Errorf("invalid field type: got %s, want C, N, L, D", string(t))
+	t, err := ParseFieldType(typ)
+	if err != nil {
+		return err
+	}
+	if bytes.IndexByte([]byte("CNLDF+B"), t) < 0 {
+		return fmt.Errorf("invalid field type: got %s, want C, N, L, D, F, +, B", string(t))
 	}
 	f.Type = t
+	if t == FieldType_Autoincrement {
+		f.setAutoIncNext(1)
+	}
 	return nil
 }
 
+// defaultFieldLen returns the length and the decimal count of a field of
+// type typ whose length is omitted:
+//
+//	"C"  254
+//	"N"  10
+//	"F"  12, with 2 decimals if dec is 0
+//
+// The length of the other types is fixed by setLen.
+func defaultFieldLen(typ byte, dec int) (length, d int) {
+	switch typ {
+	case FieldType_Character:
+		return defaultCFieldLen, 0
+	case FieldType_Numeric:
+		return defaultNFieldLen, dec
+	case FieldType_Float:
+		if dec == 0 {
+			dec = defaultFFieldDec
+		}
+		return defaultFFieldLen, dec
+	}
+	return 0, dec
+}
+
 func (f *field) setLen(length int) error {
 	switch f.Type {
 	case FieldType_Character:
@@ -139,6 +337,10 @@ func (f *field) setLen(length int) error {
 		length = defaultLFieldLen
 	case FieldType_Date:
 		length = defaultDFieldLen
+	case FieldType_Autoincrement:
+		length = defaultAutoIncFieldLen
+	case FieldType_Binary:
+		length = defaultBFieldLen
 	}
 	f.Len = byte(length)
 	return nil
@@ -163,28 +365,103 @@ func (f *field) setDec(dec int) error {
 	return nil
 }
 
+// intDigits returns the number of positions left for the integer part.
+func (f *field) intDigits() int {
+	n := int(f.Len)
+	if f.Dec > 0 {
+		n -= int(f.Dec) + 1
+	}
+	return n
+}
+
+// MaxInt returns the largest integer that fits the numeric field.
+func (f *field) MaxInt() int64 {
+	return maxIntOfDigits(f.intDigits())
+}
+
+// MinInt returns the smallest integer that fits the numeric field,
+// the minus sign takes one position.
+func (f *field) MinInt() int64 {
+	n := f.intDigits() - 1
+	if n >= 19 {
+		return math.MinInt64
+	}
+	return -maxIntOfDigits(n)
+}
+
+func maxIntOfDigits(n int) int64 {
+	if n >= 19 {
+		return math.MaxInt64
+	}
+	v := int64(0)
+	for i := 0; i < n; i++ {
+		v = v*10 + 9
+	}
+	return v
+}
+
 // read field info from io.Reader
 func (f *field) read(reader io.Reader) error {
-	err := binary.Read(reader, binary.LittleEndian, f)
-	return err
+	var d fieldDesc
+	if err := binary.Read(reader, binary.LittleEndian, &d); err != nil {
+		return err
+	}
+	*f = field{Type: d.Type, Offset: d.Offset, Len: d.Len, Dec: d.Dec, Filler: d.Filler}
+	copy(f.Name[:], d.Name[:])
+	return nil
 }
 
 func (f *field) write(writer io.Writer) error {
-	tmp := f.Offset
-	f.Offset = 0
-	defer func() { f.Offset = tmp }()
+	d := fieldDesc{Type: f.Type, Len: f.Len, Dec: f.Dec, Filler: f.Filler}
+	copy(d.Name[:], f.Name[:])
+	return binary.Write(writer, binary.LittleEndian, &d)
+}
 
-	return binary.Write(writer, binary.LittleEndian, f)
+// read7 reads a dBase 7 field descriptor.
+func (f *field) read7(reader io.Reader) error {
+	var d fieldDesc7
+	if err := binary.Read(reader, binary.LittleEndian, &d); err != nil {
+		return err
+	}
+	*f = field{Name: d.Name, Type: d.Type, Len: d.Len, Dec: d.Dec}
+	f.Filler[mdxFlagOffset] = d.MDX
+	copy(f.Filler[autoIncNextOffset:], d.AutoIncNext[:])
+	return nil
+}
+
+// write7 writes a dBase 7 field descriptor.
+func (f *field) write7(writer io.Writer) error {
+	d := fieldDesc7{Name: f.Name, Type: f.Type, Len: f.Len, Dec: f.Dec, MDX: f.Filler[mdxFlagOffset]}
+	copy(d.AutoIncNext[:], f.Filler[autoIncNextOffset:])
+	return binary.Write(writer, binary.LittleEndian, &d)
 }
 
 // Buffer
 
-func (f *field) buffer(recordBuf []byte) []byte {
-	return recordBuf[int(f.Offset) : int(f.Offset)+int(f.Len)]
+// buffer returns the field part of recordBuf or ErrTruncatedRecord if
+// recordBuf is too short.
+func (f *field) buffer(recordBuf []byte) ([]byte, error) {
+	if err := f.checkBuffer(recordBuf); err != nil {
+		return nil, err
+	}
+	return recordBuf[int(f.Offset) : int(f.Offset)+int(f.Len)], nil
+}
+
+// checkBuffer returns ErrTruncatedRecord if the field doesn't fit in recordBuf.
+func (f *field) checkBuffer(recordBuf []byte) error {
+	if int(f.Offset)+int(f.Len) > len(recordBuf) {
+		return ErrTruncatedRecord
+	}
+	return nil
 }
 
-func (f *field) setBuffer(recordBuf []byte, value string) {
-	copy(recordBuf[int(f.Offset):int(f.Offset)+int(f.Len)], value)
+func (f *field) setBuffer(recordBuf []byte, value string) error {
+	b, err := f.buffer(recordBuf)
+	if err != nil {
+		return err
+	}
+	copy(b, value)
+	return nil
 }
 
 // Check
@@ -196,9 +473,25 @@ func (f *field) checkType(t byte) error {
 	return nil
 }
 
+// checkNumValue allows the field types read by numValue.
+func (f *field) checkNumValue() error {
+	if f.Type == FieldType_Autoincrement || f.Type == FieldType_Binary {
+		return nil
+	}
+	return f.checkNumeric()
+}
+
+// checkNumeric allows both numeric field types.
+func (f *field) checkNumeric() error {
+	if f.Type != FieldType_Numeric && f.Type != FieldType_Float {
+		return fmt.Errorf("type mismatch: got numeric, want %q", string(f.Type))
+	}
+	return nil
+}
+
 func (f *field) checkLen(value string) error {
 	if len(value) > int(f.Len) {
-		return fmt.Errorf("field value overflow: value len %d, field len %d", len(value), int(f.Len))
+		return &FieldOverflowError{Field: f.name(), Value: value, Len: int(f.Len)}
 	}
 	return nil
 }
@@ -206,13 +499,24 @@ func (f *field) checkLen(value string) error {
 // Get value
 
 func (f *field) stringValue(recordBuf []byte, dec *encoding.Decoder) (string, error) {
-	s := string(f.buffer(recordBuf))
+	b, err := f.buffer(recordBuf)
+	if err != nil {
+		return "", err
+	}
+	s := string(b)
 
 	switch f.Type {
 	case FieldType_Character:
 		s = strings.TrimRight(s, " ")
 	case FieldType_Numeric, FieldType_Float:
 		s = strings.TrimLeft(s, " ")
+	case FieldType_Autoincrement:
+		if isBlank(b) {
+			return "", nil
+		}
+		s = strconv.FormatInt(f.autoIncValue(b), 10)
+	case FieldType_Binary:
+		s = strconv.FormatFloat(f.doubleValue(b), 'f', -1, 64)
 	}
 
 	if dec != nil && f.Type == FieldType_Character && !isASCII(s) {
@@ -229,7 +533,10 @@ func (f *field) boolValue(recordBuf []byte) (v bool, err error) {
 	if err = f.checkType(FieldType_Logical); err != nil {
 		return
 	}
-	fieldBuf := f.buffer(recordBuf)
+	fieldBuf, err := f.buffer(recordBuf)
+	if err != nil {
+		return
+	}
 	b := fieldBuf[0]
 	v = b == 'T' || b == 't' || b == 'Y' || b == 'y'
 	return
@@ -239,22 +546,87 @@ func (f *field) dateValue(recordBuf []byte) (d time.Time, err error) {
 	if err = f.checkType(FieldType_Date); err != nil {
 		return
 	}
-	s := string(f.buffer(recordBuf))
+	b, err := f.buffer(recordBuf)
+	if err != nil {
+		return
+	}
+	s := string(b)
 	if strings.Trim(s, " ") == "" {
 		return
 	}
 	return time.Parse("20060102", s)
 }
 
+// timestampLayout is the layout of timestamps stored in 14 byte "C" fields.
+const timestampLayout = "20060102150405"
+
+func (f *field) timestampValue(recordBuf []byte) (t time.Time, err error) {
+	if err = f.checkType(FieldType_Character); err != nil {
+		return
+	}
+	b, err := f.buffer(recordBuf)
+	if err != nil {
+		return
+	}
+	s := strings.TrimSpace(string(b))
+	if s == "" {
+		return
+	}
+	if len(s) != len(timestampLayout) {
+		return t, fmt.Errorf("invalid timestamp %q, want %s layout", s, timestampLayout)
+	}
+	return time.Parse(timestampLayout, s)
+}
+
+// clockValue parses a time of day stored as "HH:MM:SS" or "HHMMSS" text.
+func (f *field) clockValue(recordBuf []byte) (d time.Duration, err error) {
+	if err = f.checkType(FieldType_Character); err != nil {
+		return
+	}
+	b, err := f.buffer(recordBuf)
+	if err != nil {
+		return
+	}
+	s := strings.TrimSpace(string(b))
+	if s == "" {
+		return
+	}
+	digits := s
+	if len(s) == 8 && s[2] == ':' && s[5] == ':' {
+		digits = s[:2] + s[3:5] + s[6:]
+	}
+	var hms [3]int
+	if len(digits) == 6 {
+		for i := range hms {
+			if hms[i], err = strconv.Atoi(digits[2*i : 2*i+2]); err != nil {
+				break
+			}
+		}
+	}
+	if len(digits) != 6 || err != nil || hms[0] > 23 || hms[1] > 59 || hms[2] > 59 {
+		return 0, fmt.Errorf("invalid time of day %q, want HH:MM:SS or HHMMSS", s)
+	}
+	return time.Duration(hms[0])*time.Hour + time.Duration(hms[1])*time.Minute + time.Duration(hms[2])*time.Second, nil
+}
+
 func (f *field) intValue(recordBuf []byte) (val int64, err error) {
+	b, err := f.buffer(recordBuf)
+	if err != nil {
+		return
+	}
+	if f.Type == FieldType_Autoincrement {
+		return f.autoIncValue(b), nil
+	}
 	if err = f.checkType(FieldType_Numeric); err != nil {
 		return
 	}
-	s := string(f.buffer(recordBuf))
-	s = strings.TrimSpace(s)
+	s := strings.TrimSpace(string(b))
 	if s == "" || s[0] == '.' {
 		return
 	}
+	if isOverflow(s) {
+		return 0, ErrNumericOverflow
+	}
 	i := strings.IndexByte(s, '.')
 	if i > 0 {
 		s = s[0:i]
@@ -263,36 +635,256 @@ func (f *field) intValue(recordBuf []byte) (val int64, err error) {
 }
 
 func (f *field) floatValue(recordBuf []byte) (val float64, err error) {
-	if err = f.checkType(FieldType_Float); err != nil {
+	b, err := f.buffer(recordBuf)
+	if err != nil {
 		return
 	}
-	s := string(f.buffer(recordBuf))
-	s = strings.TrimSpace(s)
+	if f.Type == FieldType_Binary {
+		return f.doubleValue(b), nil
+	}
+	if err = f.checkNumeric(); err != nil {
+		return
+	}
+	s := strings.TrimSpace(string(b))
 	if s == "" || s[0] == '.' {
 		return
 	}
+	if isOverflow(s) {
+		return 0, ErrNumericOverflow
+	}
 	return strconv.ParseFloat(s, 64)
 }
 
+// value returns the value as the Go type matching the field type:
+// int64 for autoincrement and numeric fields without decimals, float64 for
+// other numeric and binary fields, bool, time.Time and trimmed string for the rest.
+// Blank numeric and date values are nil.
+func (f *field) value(recordBuf []byte, dec *encoding.Decoder) (interface{}, error) {
+	b, err := f.buffer(recordBuf)
+	if err != nil {
+		return nil, err
+	}
+	switch f.Type {
+	case FieldType_Autoincrement:
+		return f.autoIncValue(b), nil
+	case FieldType_Numeric, FieldType_Float, FieldType_Date:
+		if isBlank(b) {
+			return nil, nil
+		}
+		if f.Type != FieldType_Date && isOverflow(strings.TrimSpace(string(b))) {
+			return nil, ErrNumericOverflow
+		}
+		switch {
+		case f.Type == FieldType_Date:
+			return f.dateValue(recordBuf)
+		case f.Type == FieldType_Numeric && f.Dec == 0:
+			return f.intValue(recordBuf)
+		}
+		return f.numValue(recordBuf)
+	case FieldType_Binary:
+		return f.doubleValue(b), nil
+	case FieldType_Logical:
+		return f.boolValue(recordBuf)
+	}
+	s, err := f.stringValue(recordBuf, dec)
+	if err != nil {
+		return nil, err
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// decimalString returns the stored text of a numeric or float field without
+// padding, leading zeros and a plus sign, e.g. "-0.01" for " -.01".
+// A blank value is "".
+func (f *field) decimalString(recordBuf []byte) (string, error) {
+	if err := f.checkNumeric(); err != nil {
+		return "", err
+	}
+	b, err := f.buffer(recordBuf)
+	if err != nil {
+		return "", err
+	}
+	raw := strings.TrimSpace(string(b))
+	if raw == "" {
+		return "", nil
+	}
+	if isOverflow(raw) {
+		return "", ErrNumericOverflow
+	}
+	s := raw
+	neg := false
+	if s[0] == '-' || s[0] == '+' {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i+1:]
+	}
+	if intPart == "" && frac == "" || !isDigits(intPart) || !isDigits(frac) {
+		return "", fmt.Errorf("invalid numeric value %q", raw)
+	}
+	intPart = strings.TrimLeft(intPart, "0")
+	if intPart == "" {
+		intPart = "0"
+	}
+	s = intPart
+	if frac != "" {
+		s += "." + frac
+	}
+	if neg && strings.Trim(s, "0.") != "" {
+		s = "-" + s
+	}
+	return s, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// numValue returns the value of a numeric, float or autoincrement field
+// as float64. A blank value is 0.
+func (f *field) numValue(recordBuf []byte) (val float64, err error) {
+	b, err := f.buffer(recordBuf)
+	if err != nil {
+		return
+	}
+	switch f.Type {
+	case FieldType_Autoincrement:
+		return float64(f.autoIncValue(b)), nil
+	case FieldType_Binary:
+		return f.doubleValue(b), nil
+	}
+	if err = f.checkNumeric(); err != nil {
+		return
+	}
+	s := strings.TrimSpace(string(b))
+	if s == "" || s == "." {
+		return
+	}
+	if isOverflow(s) {
+		return 0, ErrNumericOverflow
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// Binary
+//
+// dBase IV stores "B" as an 8 byte little endian IEEE 754 double. Other
+// dialects with memo files use "B" for a memo block number; those files
+// are not supported since they need a memo file.
+
+// doubleValue and autoIncValue decode the field buffer returned by buffer.
+
+func (f *field) doubleValue(fieldBuf []byte) float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(fieldBuf))
+}
+
+func (f *field) setDoubleValue(recordBuf []byte, value float64) error {
+	b, err := f.buffer(recordBuf)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint64(b, math.Float64bits(value))
+	return nil
+}
+
+// Autoincrement
+//
+// The value is stored as a big endian long with the sign bit flipped, as the
+// dBase 7 long type. The next value is kept in the field descriptor.
+
+func (f *field) autoIncValue(fieldBuf []byte) int64 {
+	return int64(int32(binary.BigEndian.Uint32(fieldBuf) ^ 0x80000000))
+}
+
+func (f *field) setAutoIncValue(recordBuf []byte, value int64) error {
+	if value < math.MinInt32 || value > math.MaxInt32 {
+		return &FieldOverflowError{Field: f.name(), Value: strconv.FormatInt(value, 10), Len: int(f.Len)}
+	}
+	b, err := f.buffer(recordBuf)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(b, uint32(int32(value))^0x80000000)
+	return nil
+}
+
+func (f *field) autoIncNext() int64 {
+	return int64(binary.LittleEndian.Uint32(f.Filler[autoIncNextOffset:]))
+}
+
+func (f *field) setAutoIncNext(v int64) {
+	binary.LittleEndian.PutUint32(f.Filler[autoIncNextOffset:], uint32(v))
+}
+
 // Set value
 
-func (f *field) setStringValue(recordBuf []byte, value string, enc *encoding.Encoder) (err error) {
+func (f *field) setStringValue(recordBuf []byte, value string, enc *encoding.Encoder, truncate TruncatePolicy) (err error) {
 	if err = f.checkType(FieldType_Character); err != nil {
 		return
 	}
 
+	encoded := value
 	if enc != nil && !isASCII(value) {
-		s, err := enc.String(value)
-		if err != nil {
-			return err
+		if encoded, err = enc.String(value); err != nil {
+			return
 		}
-		value = s
 	}
-	if err = f.checkLen(value); err != nil {
+	if len(encoded) > int(f.Len) && truncate == TruncateCharBoundary {
+		if encoded, err = f.truncateString(value, enc); err != nil {
+			return
+		}
+	}
+	if err = f.checkLen(encoded); err != nil {
 		return
 	}
-	f.setBuffer(recordBuf, padRight(value, int(f.Len)))
-	return
+	return f.setBuffer(recordBuf, padRight(encoded, int(f.Len)))
+}
+
+// truncateString encodes the longest prefix of value that fits the field,
+// cutting it between characters so that no multi-byte character is split.
+func (f *field) truncateString(value string, enc *encoding.Encoder) (string, error) {
+	var b strings.Builder
+	for _, r := range value {
+		s := string(r)
+		if enc != nil && r >= utf8.RuneSelf {
+			es, err := enc.String(s)
+			if err != nil {
+				return "", err
+			}
+			s = es
+		}
+		if b.Len()+len(s) > int(f.Len) {
+			break
+		}
+		b.WriteString(s)
+	}
+	return b.String(), nil
+}
+
+// setBytesValue writes raw bytes, such as the output of a Marshaler, without
+// code page conversion.
+func (f *field) setBytesValue(recordBuf []byte, value []byte) (err error) {
+	if f.Type == FieldType_Memo || f.Type == FieldType_OLE {
+		// the block number in the memo file is passed through as is
+		if len(value) != int(f.Len) {
+			return fmt.Errorf("raw %q value has len %d, field len %d", string(f.Type), len(value), f.Len)
+		}
+		return f.setBuffer(recordBuf, string(value))
+	}
+	if err = f.checkType(FieldType_Character); err != nil {
+		return
+	}
+	if err = f.checkLen(string(value)); err != nil {
+		return
+	}
+	return f.setBuffer(recordBuf, padRight(string(value), int(f.Len)))
 }
 
 func (f *field) setBoolValue(recordBuf []byte, value bool) (err error) {
@@ -303,19 +895,79 @@ func (f *field) setBoolValue(recordBuf []byte, value bool) (err error) {
 	if value {
 		s = "T"
 	}
-	f.setBuffer(recordBuf, s)
+	return f.setBuffer(recordBuf, s)
+}
+
+// setLogicalFormat replaces the "T" and "F" written by setBoolValue.
+func (f *field) setLogicalFormat(recordBuf []byte, yes, no byte) error {
+	b, err := f.buffer(recordBuf)
+	if err != nil {
+		return err
+	}
+	switch b[0] {
+	case 'T':
+		b[0] = yes
+	case 'F':
+		b[0] = no
+	}
 	return nil
 }
 
+// setDateValue writes value as "YYYYMMDD", zero time is written as blanks.
 func (f *field) setDateValue(recordBuf []byte, value time.Time) (err error) {
 	if err = f.checkType(FieldType_Date); err != nil {
 		return
 	}
-	f.setBuffer(recordBuf, value.Format("20060102"))
-	return
+	if value.IsZero() {
+		return f.setBuffer(recordBuf, strings.Repeat(" ", int(f.Len)))
+	}
+	return f.setBuffer(recordBuf, value.Format("20060102"))
+}
+
+// setClockValue writes the time of day d as "HH:MM:SS" if the field is long
+// enough, otherwise as "HHMMSS".
+func (f *field) setClockValue(recordBuf []byte, d time.Duration) (err error) {
+	if err = f.checkType(FieldType_Character); err != nil {
+		return
+	}
+	if d < 0 || d >= 24*time.Hour {
+		return fmt.Errorf("time of day %s out of range", d)
+	}
+	sec := int(d / time.Second)
+	h, m, s := sec/3600, sec/60%60, sec%60
+	var v string
+	switch {
+	case f.Len >= 8:
+		v = fmt.Sprintf("%02d:%02d:%02d", h, m, s)
+	case f.Len >= 6:
+		v = fmt.Sprintf("%02d%02d%02d", h, m, s)
+	default:
+		return fmt.Errorf("field len %d is too short for a time of day", f.Len)
+	}
+	return f.setBuffer(recordBuf, padRight(v, int(f.Len)))
+}
+
+func (f *field) setTimestampValue(recordBuf []byte, value time.Time) (err error) {
+	if err = f.checkType(FieldType_Character); err != nil {
+		return
+	}
+	s := ""
+	if !value.IsZero() {
+		s = value.Format(timestampLayout)
+	}
+	if err = f.checkLen(s); err != nil {
+		return
+	}
+	return f.setBuffer(recordBuf, padRight(s, int(f.Len)))
 }
 
 func (f *field) setIntValue(recordBuf []byte, value int64) (err error) {
+	switch f.Type {
+	case FieldType_Autoincrement:
+		return f.setAutoIncValue(recordBuf, value)
+	case FieldType_Binary:
+		return f.setDoubleValue(recordBuf, float64(value))
+	}
 	if err = f.checkType(FieldType_Numeric); err != nil {
 		return
 	}
@@ -326,26 +978,212 @@ func (f *field) setIntValue(recordBuf []byte, value int64) (err error) {
 	if err = f.checkLen(s); err != nil {
 		return
 	}
-	f.setBuffer(recordBuf, padLeft(s, int(f.Len)))
-	return
+	return f.setBuffer(recordBuf, padLeft(s, int(f.Len)))
 }
 
 func (f *field) setFloatValue(recordBuf []byte, value float64) (err error) {
+	if f.Type == FieldType_Binary {
+		return f.setDoubleValue(recordBuf, value)
+	}
 	if err = f.checkType(FieldType_Float); err != nil {
 		return
 	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("invalid numeric value: %v", value)
+	}
+	// 'f' format never uses exponent notation
 	s := strconv.FormatFloat(value, 'f', int(f.Dec), 64)
 	if err = f.checkLen(s); err != nil {
 		return
 	}
-	f.setBuffer(recordBuf, padLeft(s, int(f.Len)))
+	return f.setBuffer(recordBuf, padLeft(s, int(f.Len)))
+}
+
+// NumericPadding defines how numeric values are padded to the field length.
+type NumericPadding int
+
+const (
+	// PaddingSpace pads numeric values with leading spaces.
+	PaddingSpace NumericPadding = iota
+	// PaddingZero pads numeric values with leading zeros after the sign.
+	PaddingZero
+)
+
+// TruncatePolicy defines how character values longer than the field are
+// handled.
+type TruncatePolicy int
+
+const (
+	// TruncateError fails with a FieldOverflowError.
+	TruncateError TruncatePolicy = iota
+	// TruncateCharBoundary cuts the value to the field length between
+	// characters of the code page, never in the middle of a multi-byte
+	// character.
+	TruncateCharBoundary
+)
+
+// valueOptions holds the XBase settings used to write field values.
+type valueOptions struct {
+	enc      *encoding.Encoder
+	padding  NumericPadding
+	lenient  bool
+	truncate TruncatePolicy
+	// logical holds the true and false bytes of logical fields if set
+	logical [2]byte
+}
+
+// zeroPad replaces the leading spaces of a numeric field with zeros,
+// keeping the minus sign in front.
+func (f *field) zeroPad(recordBuf []byte) error {
+	b, err := f.buffer(recordBuf)
+	if err != nil {
+		return err
+	}
+	n := 0
+	for n < len(b) && b[n] == ' ' {
+		n++
+	}
+	if n == 0 || n == len(b) {
+		return nil
+	}
+	start := 0
+	if b[n] == '-' {
+		b[n] = '0'
+		b[0] = '-'
+		start = 1
+	}
+	for i := start; i < n; i++ {
+		b[i] = '0'
+	}
+	return nil
+}
+
+func (f *field) setValue(recordBuf []byte, value interface{}, opts valueOptions) (err error) {
+	if opts.lenient {
+		if value, err = f.convert(value); err != nil {
+			return
+		}
+	}
+	if err = f.setValueDefault(recordBuf, value, opts); err != nil {
+		return
+	}
+	if (opts.padding == PaddingZero || f.zeroPadded) && (f.Type == FieldType_Numeric || f.Type == FieldType_Float) {
+		err = f.zeroPad(recordBuf)
+	}
+	if opts.logical[0] != 0 && f.Type == FieldType_Logical {
+		err = f.setLogicalFormat(recordBuf, opts.logical[0], opts.logical[1])
+	}
 	return
 }
 
-func (f *field) setValue(recordBuf []byte, value interface{}, enc *encoding.Encoder) (err error) {
+// convert converts value to a type accepted by the field type: strings are
+// parsed as numbers, booleans and dates, numbers are converted between
+// integers and floats, and scalar values are formatted for character fields.
+// Other values are returned unchanged.
+func (f *field) convert(value interface{}) (interface{}, error) {
+	switch f.Type {
+	case FieldType_Numeric, FieldType_Float, FieldType_Autoincrement, FieldType_Binary:
+		if s, ok := value.(string); ok {
+			s = strings.TrimSpace(s)
+			i, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				fl, err := strconv.ParseFloat(s, 64)
+				if err != nil {
+					return nil, err
+				}
+				return f.convertNumber(fl), nil
+			}
+			value = i
+		}
+		return f.convertNumber(value), nil
+	case FieldType_Logical:
+		switch v := value.(type) {
+		case string:
+			s := strings.ToUpper(strings.TrimSpace(v))
+			switch s {
+			case "T", "Y", "TRUE", "YES", "1":
+				return true, nil
+			case "F", "N", "FALSE", "NO", "0":
+				return false, nil
+			}
+			return nil, fmt.Errorf("invalid logical value %q", v)
+		case int:
+			return v != 0, nil
+		case int64:
+			return v != 0, nil
+		}
+	case FieldType_Date:
+		if s, ok := value.(string); ok {
+			return time.Parse("20060102", strings.TrimSpace(s))
+		}
+	case FieldType_Character:
+		switch v := value.(type) {
+		case bool:
+			if v {
+				return "T", nil
+			}
+			return "F", nil
+		case time.Time:
+			return v.Format("20060102"), nil
+		case float32:
+			return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return fmt.Sprint(v), nil
+		}
+	}
+	return value, nil
+}
+
+// convertNumber converts integers to float64 for float fields and floats to
+// int64, rounded, for numeric fields without decimals. Other values are
+// returned unchanged.
+func (f *field) convertNumber(value interface{}) interface{} {
+	switch f.Type {
+	case FieldType_Float:
+		switch v := value.(type) {
+		case int:
+			return float64(v)
+		case int8:
+			return float64(v)
+		case int16:
+			return float64(v)
+		case int32:
+			return float64(v)
+		case int64:
+			return float64(v)
+		case uint:
+			return float64(v)
+		case uint8:
+			return float64(v)
+		case uint16:
+			return float64(v)
+		case uint32:
+			return float64(v)
+		case uint64:
+			return float64(v)
+		}
+	case FieldType_Numeric:
+		if f.Dec > 0 {
+			break
+		}
+		switch v := value.(type) {
+		case float32:
+			return int64(math.Round(float64(v)))
+		case float64:
+			return int64(math.Round(v))
+		}
+	}
+	return value
+}
+
+func (f *field) setValueDefault(recordBuf []byte, value interface{}, opts valueOptions) (err error) {
 	switch v := value.(type) {
 	case string:
-		err = f.setStringValue(recordBuf, v, enc)
+		err = f.setStringValue(recordBuf, v, opts.enc, opts.truncate)
+	case []byte:
+		err = f.setBytesValue(recordBuf, v)
 	case bool:
 		err = f.setBoolValue(recordBuf, v)
 	case int:
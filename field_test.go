@@ -3,15 +3,17 @@ package xbase
 import (
 	"bytes"
 	"github.com/stretchr/testify/assert"
+	"math"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/simplifiedchinese"
 )
 
 func TestFieldName(t *testing.T) {
 	f := &field{
-		Name: [11]byte{'N', 'A', 'M', 'E', 0, 0, 0, 0, 0, 0},
+		Name: [maxLongFieldNameLen]byte{'N', 'A', 'M', 'E', 0, 0, 0, 0, 0, 0},
 	}
 	require.Equal(t, "NAME", f.name())
 }
@@ -52,6 +54,34 @@ func TestNewField(t *testing.T) {
 	require.Equal(t, byte(2), f.Dec)
 }
 
+func TestNewFieldDefaultLen(t *testing.T) {
+	tests := []struct {
+		typ      string
+		opts     []int
+		len, dec byte
+	}{
+		{"C", nil, 254, 0},
+		{"N", nil, 10, 0},
+		{"N", []int{0, 3}, 10, 3},
+		{"F", nil, 12, 2},
+		{"F", []int{0, 4}, 12, 4},
+		{"L", nil, 1, 0},
+		{"D", nil, 8, 0},
+		{"+", nil, 4, 0},
+		{"B", nil, 8, 0},
+		{"C", []int{5}, 5, 0},
+		{"F", []int{8}, 8, 0},
+	}
+	for _, tt := range tests {
+		db := MustNew(nil)
+		require.NoError(t, db.AddField("X", tt.typ, tt.opts...), tt.typ)
+		assert.Equal(t, tt.len, db.fields[0].Len, "%s %v", tt.typ, tt.opts)
+		assert.Equal(t, tt.dec, db.fields[0].Dec, "%s %v", tt.typ, tt.opts)
+	}
+	_, err := NewField("X", "C", -1, 0)
+	assert.Error(t, err)
+}
+
 func TestReadField(t *testing.T) {
 	b := make([]byte, fieldSize)
 	copy(b[:], "NAME")
@@ -101,8 +131,12 @@ func TestFieldBuffer(t *testing.T) {
 	assert.NoError(t, err)
 	f.Offset = 6
 	recordBuf := []byte(" Abc  T 12")
-	buf := f.buffer(recordBuf)
+	buf, err := f.buffer(recordBuf)
+	require.NoError(t, err)
 	require.Equal(t, []byte("T"), buf)
+	_, err = f.buffer(recordBuf[:6])
+	require.ErrorIs(t, err, ErrTruncatedRecord)
+	require.ErrorIs(t, f.setBuffer(recordBuf[:6], "F"), ErrTruncatedRecord)
 }
 
 func TestFieldStringValue(t *testing.T) {
@@ -157,6 +191,24 @@ func TestFieldFloatValue(t *testing.T) {
 	require.Equal(t, float64(-20.21), v)
 }
 
+func TestFieldNumericDecimalValue(t *testing.T) {
+	f, err := NewField("PRICE", "N", 10, 2)
+	assert.NoError(t, err)
+	f.Offset = 1
+	recordBuf := []byte("   -1234.56")
+	v, err := f.floatValue(recordBuf)
+	assert.NoError(t, err)
+	require.Equal(t, -1234.56, v)
+	i, err := f.intValue(recordBuf)
+	assert.NoError(t, err)
+	require.Equal(t, int64(-1234), i)
+
+	recordBuf = []byte("           ")
+	v, err = f.floatValue(recordBuf)
+	assert.NoError(t, err)
+	require.Equal(t, 0.0, v)
+}
+
 func TestFieldSetBuffer(t *testing.T) {
 	f, err := NewField("Log", "L", 1, 0)
 	assert.NoError(t, err)
@@ -171,10 +223,33 @@ func TestFieldSetStringValue(t *testing.T) {
 	f, err := NewField("NAME", "C", 5, 0)
 	assert.NoError(t, err)
 	f.Offset = 5
-	f.setStringValue(recordBuf, " Abc", nil)
+	f.setStringValue(recordBuf, " Abc", nil, TruncateError)
 	require.Equal(t, []byte(" Abc "), recordBuf[5:10])
 }
 
+func TestFieldSetStringValueTruncate(t *testing.T) {
+	enc := simplifiedchinese.GBK.NewEncoder()
+	f, err := NewField("NAME", "C", 5, 0)
+	require.NoError(t, err)
+	f.Offset = 1
+	recordBuf := make([]byte, 6)
+
+	// 4 characters of 2 bytes each in GBK
+	err = f.setStringValue(recordBuf, "中文字符", enc, TruncateError)
+	var foe *FieldOverflowError
+	assert.ErrorAs(t, err, &foe)
+
+	require.NoError(t, f.setStringValue(recordBuf, "中文字符", enc, TruncateCharBoundary))
+	s, err := simplifiedchinese.GBK.NewDecoder().Bytes(recordBuf[1:])
+	require.NoError(t, err)
+	assert.Equal(t, "中文 ", string(s))
+
+	require.NoError(t, f.setStringValue(recordBuf, "a中文字", enc, TruncateCharBoundary))
+	s, err = simplifiedchinese.GBK.NewDecoder().Bytes(recordBuf[1:])
+	require.NoError(t, err)
+	assert.Equal(t, "a中文", string(s))
+}
+
 func TestFieldSetBoolValue(t *testing.T) {
 	recordBuf := make([]byte, 20)
 	f, err := NewField("NAME", "L", 1, 0)
@@ -211,3 +286,231 @@ func TestFieldSetFloatValue(t *testing.T) {
 	f.setFloatValue(recordBuf, 123.45)
 	require.Equal(t, []byte("  123.45"), recordBuf[5:13])
 }
+
+func TestFieldSetValueOverflow(t *testing.T) {
+	recordBuf := make([]byte, 20)
+	f, err := NewField("AMOUNT", "F", 8, 0)
+	assert.NoError(t, err)
+	f.Offset = 1
+
+	err = f.setFloatValue(recordBuf, 1e20)
+	var foe *FieldOverflowError
+	if assert.ErrorAs(t, err, &foe) {
+		require.Equal(t, "AMOUNT", foe.Field)
+		require.Equal(t, "100000000000000000000", foe.Value)
+		require.Equal(t, 8, foe.Len)
+	}
+	require.EqualError(t, err, `field "AMOUNT" value overflow: "100000000000000000000" has len 21, field len 8`)
+
+	require.NoError(t, f.setFloatValue(recordBuf, 1e7))
+	require.Equal(t, []byte("10000000"), recordBuf[1:9])
+	require.Error(t, f.setFloatValue(recordBuf, math.NaN()))
+
+	f, err = NewField("AMOUNT", "N", 8, 0)
+	assert.NoError(t, err)
+	f.Offset = 1
+	err = f.setIntValue(recordBuf, 123456789)
+	require.ErrorAs(t, err, &foe)
+}
+
+func TestFieldNegativeOverflow(t *testing.T) {
+	recordBuf := make([]byte, 20)
+	f, err := NewField("NUM", "N", 5, 0)
+	assert.NoError(t, err)
+	f.Offset = 1
+	require.Equal(t, int64(99999), f.MaxInt())
+	require.Equal(t, int64(-9999), f.MinInt())
+	err = f.setIntValue(recordBuf, -12345)
+	require.EqualError(t, err, `field "NUM" value overflow: "-12345" has len 6, field len 5 (the minus sign takes one position)`)
+
+	f, err = NewField("NUM", "N", 6, 0)
+	assert.NoError(t, err)
+	f.Offset = 1
+	require.NoError(t, f.setIntValue(recordBuf, -12345))
+	require.Equal(t, []byte("-12345"), recordBuf[1:7])
+
+	f, err = NewField("NUM", "N", 8, 2)
+	assert.NoError(t, err)
+	require.Equal(t, int64(99999), f.MaxInt())
+	require.Equal(t, int64(-9999), f.MinInt())
+}
+
+func TestFieldSetValueZeroPadding(t *testing.T) {
+	recordBuf := make([]byte, 20)
+	opts := valueOptions{padding: PaddingZero}
+	f, err := NewField("NUM", "N", 5, 0)
+	assert.NoError(t, err)
+	f.Offset = 1
+	require.NoError(t, f.setValue(recordBuf, 123, opts))
+	require.Equal(t, []byte("00123"), recordBuf[1:6])
+	v, err := f.intValue(recordBuf)
+	require.NoError(t, err)
+	require.Equal(t, int64(123), v)
+
+	require.NoError(t, f.setValue(recordBuf, -123, opts))
+	require.Equal(t, []byte("-0123"), recordBuf[1:6])
+	v, err = f.intValue(recordBuf)
+	require.NoError(t, err)
+	require.Equal(t, int64(-123), v)
+
+	require.NoError(t, f.setValue(recordBuf, 12345, opts))
+	require.Equal(t, []byte("12345"), recordBuf[1:6])
+
+	f, err = NewField("NUM", "F", 8, 2)
+	assert.NoError(t, err)
+	f.Offset = 1
+	require.NoError(t, f.setValue(recordBuf, -1.5, opts))
+	require.Equal(t, []byte("-0001.50"), recordBuf[1:9])
+	fv, err := f.floatValue(recordBuf)
+	require.NoError(t, err)
+	require.Equal(t, -1.5, fv)
+}
+
+func TestFieldTimestampValue(t *testing.T) {
+	f, err := NewField("TS", "C", 14, 0)
+	assert.NoError(t, err)
+	f.Offset = 1
+	v, err := f.timestampValue([]byte(" 20210212143000"))
+	assert.NoError(t, err)
+	require.Equal(t, time.Date(2021, 2, 12, 14, 30, 0, 0, time.UTC), v)
+
+	v, err = f.timestampValue([]byte("               "))
+	assert.NoError(t, err)
+	require.True(t, v.IsZero())
+
+	_, err = f.timestampValue([]byte(" 20210212      "))
+	require.Error(t, err)
+}
+
+func TestFieldClockValue(t *testing.T) {
+	f, err := NewField("AT", "C", 8, 0)
+	assert.NoError(t, err)
+	f.Offset = 1
+	want := 12*time.Hour + 34*time.Minute + 56*time.Second
+	for _, s := range []string{" 12:34:56", " 123456  ", "   123456"} {
+		v, err := f.clockValue([]byte(s))
+		assert.NoError(t, err, s)
+		require.Equal(t, want, v, s)
+	}
+	v, err := f.clockValue([]byte("         "))
+	assert.NoError(t, err)
+	require.Equal(t, time.Duration(0), v)
+	for _, s := range []string{" 12:34   ", " 24:00:00", " 12:60:00", " 1234567 ", " ab:cd:ef"} {
+		_, err = f.clockValue([]byte(s))
+		require.Error(t, err, s)
+	}
+}
+
+func TestFieldDoubleValue(t *testing.T) {
+	f, err := NewField("RATIO", "B", 0, 2)
+	require.NoError(t, err)
+	require.Equal(t, byte(8), f.Len)
+	require.Equal(t, byte(0), f.Dec)
+	f.Offset = 1
+	recordBuf := make([]byte, 9)
+
+	require.NoError(t, f.setValue(recordBuf, -1.25, valueOptions{}))
+	require.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 0xF4, 0xBF}, recordBuf)
+	v, err := f.floatValue(recordBuf)
+	require.NoError(t, err)
+	require.Equal(t, -1.25, v)
+	s, err := f.stringValue(recordBuf, nil)
+	require.NoError(t, err)
+	require.Equal(t, "-1.25", s)
+
+	require.NoError(t, f.setValue(recordBuf, 3, valueOptions{}))
+	v, err = f.floatValue(recordBuf)
+	require.NoError(t, err)
+	require.Equal(t, 3.0, v)
+}
+
+func TestFieldDecimalString(t *testing.T) {
+	f, err := NewField("PRICE", "N", 8, 2)
+	require.NoError(t, err)
+	f.Offset = 1
+	tests := []struct {
+		stored string
+		want   string
+	}{
+		{"  123.45", "123.45"},
+		{"   -0.01", "-0.01"},
+		{"    -.01", "-0.01"},
+		{"-0000.50", "-0.50"},
+		{"  +12.00", "12.00"},
+		{"   -0.00", "0.00"},
+		{"     12.", "12"},
+		{"        ", ""},
+	}
+	for _, tt := range tests {
+		s, err := f.decimalString([]byte(" " + tt.stored))
+		require.NoError(t, err, tt.stored)
+		require.Equal(t, tt.want, s, tt.stored)
+	}
+	_, err = f.decimalString([]byte("   12.3.4"))
+	require.Error(t, err)
+	_, err = f.decimalString([]byte("        ."))
+	require.Error(t, err)
+}
+
+func TestField7ReadWrite(t *testing.T) {
+	f, err := newField("CUSTOMER_NAME", "C", 20, 0, maxLongFieldNameLen)
+	require.NoError(t, err)
+	f.setAutoIncNext(7)
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, f.write7(buf))
+	require.Equal(t, fieldSize7, buf.Len())
+	b := buf.Bytes()
+	require.Equal(t, byte('C'), b[32])
+	require.Equal(t, byte(20), b[33])
+	require.Equal(t, byte(7), b[40])
+
+	g := &field{}
+	require.NoError(t, g.read7(bytes.NewReader(b)))
+	require.Equal(t, "CUSTOMER_NAME", g.name())
+	require.Equal(t, byte(20), g.Len)
+	require.Equal(t, int64(7), g.autoIncNext())
+
+	_, err = NewField("CUSTOMER_NAME", "C", 20, 0)
+	require.Error(t, err)
+}
+
+func TestFieldNumericOverflowRead(t *testing.T) {
+	recordBuf := []byte(" ******")
+	f, err := NewField("NUM", "N", 6, 0)
+	require.NoError(t, err)
+	f.Offset = 1
+
+	_, err = f.intValue(recordBuf)
+	require.ErrorIs(t, err, ErrNumericOverflow)
+	_, err = f.floatValue(recordBuf)
+	require.ErrorIs(t, err, ErrNumericOverflow)
+	_, err = f.decimalString(recordBuf)
+	require.ErrorIs(t, err, ErrNumericOverflow)
+	v, err := f.value(recordBuf, nil)
+	require.ErrorIs(t, err, ErrNumericOverflow)
+	require.Nil(t, v)
+}
+
+func TestParseFieldType(t *testing.T) {
+	for _, s := range []string{"N", "n", "Numeric", " numeric "} {
+		typ, err := ParseFieldType(s)
+		require.NoError(t, err, s)
+		require.Equal(t, byte(FieldType_Numeric), typ)
+	}
+	typ, err := ParseFieldType("autoincrement")
+	require.NoError(t, err)
+	require.Equal(t, byte(FieldType_Autoincrement), typ)
+	_, err = ParseFieldType("X")
+	require.Error(t, err)
+	_, err = ParseFieldType("num")
+	require.Error(t, err)
+
+	require.Equal(t, "Character", FieldTypeName('C'))
+	require.Equal(t, "Logical", FieldTypeName(FieldType_Logical))
+	require.Equal(t, "Unknown", FieldTypeName('X'))
+
+	f := &field{}
+	require.NoError(t, f.setType("float"))
+	require.Equal(t, byte('F'), f.Type)
+	require.Error(t, f.setType("memo"))
+}
@@ -32,12 +32,46 @@ func (h *header) read(reader io.Reader) error {
 	if err := binary.Read(reader, binary.LittleEndian, h); err != nil {
 		return err
 	}
-	if h.DbfId != dbfId {
+	if h.DbfId != dbfId && h.DbfId != dbfId7 {
 		return fmt.Errorf("not DBF file")
 	}
 	return nil
 }
 
+// plausible reports whether the header looks valid beyond the DbfId byte.
+func (h *header) plausible() bool {
+	n := int(h.DataOffset) - h.size() - 1
+	// dBase 7 field properties may follow the field descriptors
+	return n >= 0 && (h.level7() || n%fieldSize == 0) && h.RecSize > 0 &&
+		h.ModMonth >= 1 && h.ModMonth <= 12 && h.ModDay >= 1 && h.ModDay <= 31
+}
+
+// dBase 7
+//
+// A dBase 7 header is followed by 32 bytes of the language driver name and
+// 4 reserved bytes, its field descriptors are 48 bytes long with names of up
+// to 32 characters.
+
+func (h *header) level7() bool {
+	return h.DbfId == dbfId7
+}
+
+// size returns the length of the header up to the field descriptors.
+func (h *header) size() int {
+	if h.level7() {
+		return headerSize7
+	}
+	return headerSize
+}
+
+// fieldSize returns the length of a field descriptor.
+func (h *header) fieldSize() int {
+	if h.level7() {
+		return fieldSize7
+	}
+	return fieldSize
+}
+
 func (h *header) write(writer io.Writer) error {
 	if err := binary.Write(writer, binary.LittleEndian, h); err != nil {
 		return err
@@ -48,11 +82,11 @@ func (h *header) write(writer io.Writer) error {
 // Field count
 
 func (h *header) fieldCount() int {
-	return (int(h.DataOffset) - headerSize - 1) / fieldSize
+	return (int(h.DataOffset) - h.size() - 1) / h.fieldSize()
 }
 
 func (h *header) setFieldCount(count int) {
-	h.DataOffset = uint16(count*fieldSize + headerSize + 1)
+	h.DataOffset = uint16(count*h.fieldSize() + h.size() + 1)
 }
 
 // Modified date
@@ -70,6 +104,70 @@ func (h *header) setModDate(d time.Time) {
 	h.ModDay = byte(d.Day())
 }
 
+// Extended timestamps
+//
+// Some tools store unix timestamps in the multi-user reserved area of the
+// header: bytes 16-19 the creation time and bytes 20-23 the modification time.
+// Zero means the timestamp is absent.
+
+const (
+	createdAtOffset  = 16
+	modifiedAtOffset = 20
+	filler1Offset    = 12
+)
+
+func (h *header) extTime(offset int) (time.Time, bool) {
+	i := offset - filler1Offset
+	sec := binary.LittleEndian.Uint32(h.Filler1[i : i+4])
+	if sec == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(sec), 0).UTC(), true
+}
+
+func (h *header) createdAt() time.Time {
+	if t, ok := h.extTime(createdAtOffset); ok {
+		return t
+	}
+	return h.modDate()
+}
+
+func (h *header) modifiedAt() time.Time {
+	if t, ok := h.extTime(modifiedAtOffset); ok {
+		return t
+	}
+	return h.modDate()
+}
+
+// Table flags
+//
+// Byte 28 of the header holds the table flags, 0x01 means the table has a
+// production index file with the same base name as the DBF file, 0x02 means
+// the table has memo fields stored in a memo file.
+
+const (
+	tableFlagsOffset    = 28
+	flagProductionIndex = 0x01
+	flagMemo            = 0x02
+	productionIndexExt  = ".mdx"
+)
+
+func (h *header) flag(flag byte) bool {
+	return h.Filler1[tableFlagsOffset-filler1Offset]&flag != 0
+}
+
+func (h *header) setFlag(flag byte, b bool) {
+	if b {
+		h.Filler1[tableFlagsOffset-filler1Offset] |= flag
+	} else {
+		h.Filler1[tableFlagsOffset-filler1Offset] &^= flag
+	}
+}
+
+func (h *header) hasProductionIndex() bool {
+	return h.flag(flagProductionIndex)
+}
+
 // Code page
 
 func (h *header) codePage() int {
@@ -2,6 +2,7 @@ package xbase
 
 import (
 	"bytes"
+	"encoding/binary"
 	"testing"
 	"time"
 
@@ -74,3 +75,21 @@ func TestHeaderSetCodePage(t *testing.T) {
 	require.Equal(t, byte(0x65), h.CP)
 	require.Equal(t, 866, h.codePage())
 }
+
+func TestHeaderExtendedTimestamps(t *testing.T) {
+	h := &header{}
+	h.setModDate(time.Date(2021, 2, 12, 15, 4, 5, 0, time.UTC))
+	midnight := time.Date(2021, 2, 12, 0, 0, 0, 0, time.UTC)
+	require.Equal(t, midnight, h.createdAt())
+	require.Equal(t, midnight, h.modifiedAt())
+
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	modified := time.Date(2021, 2, 12, 15, 4, 5, 0, time.UTC)
+	b := make([]byte, headerSize)
+	b[0] = dbfId
+	binary.LittleEndian.PutUint32(b[createdAtOffset:], uint32(created.Unix()))
+	binary.LittleEndian.PutUint32(b[modifiedAtOffset:], uint32(modified.Unix()))
+	require.NoError(t, h.read(bytes.NewReader(b)))
+	require.Equal(t, created, h.createdAt())
+	require.Equal(t, modified, h.modifiedAt())
+}
@@ -0,0 +1,168 @@
+package xbase
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Index is an in-memory index of the values of one field, sorted for binary
+// search lookups. It is built by BuildIndex from the records at that time and
+// doesn't follow later changes, so it has to be rebuilt after the file is
+// modified or reopened.
+type Index struct {
+	db      *XBase
+	f       *field
+	numeric bool
+	entries []indexEntry
+	// pos is the entry of the last found record, -1 if none
+	pos int
+}
+
+type indexEntry struct {
+	str   string
+	num   float64
+	recNo int64
+}
+
+// BuildIndex reads the value of the field of every not deleted record and
+// returns an index over them. Fields are numbered starting from 1.
+// Numeric fields are compared as numbers, other fields as trimmed strings.
+func (db *XBase) BuildIndex(fieldNo int) (*Index, error) {
+	if fieldNo < 1 || fieldNo > len(db.fields) {
+		return nil, fmt.Errorf("xbase: BuildIndex: field number %d out of range", fieldNo)
+	}
+	f := db.fields[fieldNo-1]
+	idx := &Index{db: db, f: f, numeric: f.checkNumValue() == nil, pos: -1}
+	err := db.scan(func(recNo int64, buf []byte) error {
+		if recNo < db.firstRecNo() || buf[0] == '*' {
+			return nil
+		}
+		e := indexEntry{recNo: recNo}
+		var err error
+		if idx.numeric {
+			e.num, err = f.numValue(buf)
+		} else {
+			e.str, err = f.stringValue(buf, db.decoder)
+			e.str = strings.TrimSpace(e.str)
+		}
+		if err != nil {
+			return fmt.Errorf("xbase: BuildIndex: field %q: %w", f.name(), err)
+		}
+		idx.entries = append(idx.entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(idx.entries, func(i, j int) bool {
+		return idx.compare(idx.entries[i], idx.entries[j]) < 0
+	})
+	return idx, nil
+}
+
+// Len returns the number of indexed records.
+func (idx *Index) Len() int {
+	return len(idx.entries)
+}
+
+// Seek positions the XBase to the first record whose value equals value and
+// returns its number. It returns false and keeps the position if there is
+// no such record.
+//
+// Numeric fields take integer and float values, date fields a time.Time or
+// a "YYYYMMDD" string, other fields a string.
+func (idx *Index) Seek(value interface{}) (int64, bool) {
+	key, err := idx.key(value)
+	if err != nil {
+		return 0, false
+	}
+	i := sort.Search(len(idx.entries), func(i int) bool {
+		return idx.compare(idx.entries[i], key) >= 0
+	})
+	if i == len(idx.entries) || idx.compare(idx.entries[i], key) != 0 {
+		return 0, false
+	}
+	return idx.goTo(i)
+}
+
+// Next positions the XBase to the next record with the same value as the
+// record found by the last Seek or Next. It returns false and keeps the
+// position if there is none.
+func (idx *Index) Next() (int64, bool) {
+	i := idx.pos + 1
+	if idx.pos < 0 || i >= len(idx.entries) || idx.compare(idx.entries[i], idx.entries[idx.pos]) != 0 {
+		return 0, false
+	}
+	return idx.goTo(i)
+}
+
+func (idx *Index) goTo(i int) (int64, bool) {
+	recNo := idx.entries[i].recNo
+	if err := idx.db.GoTo(recNo); err != nil {
+		return 0, false
+	}
+	idx.pos = i
+	return recNo, true
+}
+
+func (idx *Index) compare(a, b indexEntry) int {
+	if idx.numeric {
+		switch {
+		case a.num < b.num:
+			return -1
+		case a.num > b.num:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a.str, b.str)
+}
+
+// key converts a Seek value to an entry to compare with.
+func (idx *Index) key(value interface{}) (e indexEntry, err error) {
+	if idx.numeric {
+		switch v := value.(type) {
+		case int:
+			e.num = float64(v)
+		case int8:
+			e.num = float64(v)
+		case int16:
+			e.num = float64(v)
+		case int32:
+			e.num = float64(v)
+		case int64:
+			e.num = float64(v)
+		case uint:
+			e.num = float64(v)
+		case uint8:
+			e.num = float64(v)
+		case uint16:
+			e.num = float64(v)
+		case uint32:
+			e.num = float64(v)
+		case uint64:
+			e.num = float64(v)
+		case float32:
+			e.num = float64(v)
+		case float64:
+			e.num = v
+		default:
+			err = fmt.Errorf("xbase: can't seek %T in numeric field %q", value, idx.f.name())
+		}
+		return
+	}
+	switch v := value.(type) {
+	case string:
+		e.str = strings.TrimSpace(v)
+	case time.Time:
+		if idx.f.Type != FieldType_Date {
+			return e, fmt.Errorf("xbase: can't seek time.Time in field %q", idx.f.name())
+		}
+		e.str = v.Format("20060102")
+	default:
+		err = fmt.Errorf("xbase: can't seek %T in field %q", value, idx.f.name())
+	}
+	return
+}
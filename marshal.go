@@ -0,0 +1,131 @@
+package xbase
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MarshalAutoSize returns the DBF encoding of v, which must be a struct,
+// struct slice or struct array.
+//
+// Character fields whose tag omits len are sized to the longest encoded value
+// in v, capped at 254 bytes. This needs two passes over v, one to measure the
+// values and one to encode them, so it is not available for streaming
+// encoders.
+func MarshalAutoSize(v interface{}) ([]byte, error) {
+	val := walkValue(reflect.ValueOf(v))
+	if !val.IsValid() {
+		return nil, &InvalidMarshalError{}
+	}
+	switch val.Kind() {
+	case reflect.Struct:
+		s := reflect.MakeSlice(reflect.SliceOf(val.Type()), 0, 1)
+		val = reflect.Append(s, val)
+	case reflect.Slice, reflect.Array:
+		if walkType(val.Type().Elem()).Kind() != reflect.Struct {
+			return nil, &InvalidMarshalError{Type: reflect.TypeOf(v)}
+		}
+	default:
+		return nil, &InvalidMarshalError{Type: reflect.TypeOf(v)}
+	}
+
+	buf := NewSeekableBuffer()
+	db, err := New(buf)
+	if err != nil {
+		return nil, err
+	}
+	enc := NewEncoder(db)
+	if enc.autoLen, err = enc.measure(val); err != nil {
+		return nil, err
+	}
+	if err = enc.encodeHeader(walkType(val.Type().Elem())); err != nil {
+		return nil, err
+	}
+	if err = enc.encodeArray(val); err != nil {
+		return nil, err
+	}
+	if err = db.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// measure returns the longest encoded value of every character field of the
+// elements of v which has no length in its tag.
+func (e *Encoder) measure(v reflect.Value) (map[string]int, error) {
+	fields := cachedFields(typeKey{e.tag(), walkType(v.Type().Elem())})
+	lens := make(map[string]int)
+	for _, f := range fields {
+		if f.tag.length != 0 || f.tag.dbfType != string(FieldType_Character) {
+			continue
+		}
+		fn, err := encodeFn(f.baseType, true, e.funcMap, e.ifaceFuncs)
+		if err != nil {
+			return nil, err
+		}
+		max := 1
+		for i := 0; i < v.Len(); i++ {
+			fv := walkIndex(walkValue(v.Index(i)), f.index)
+			if !fv.IsValid() {
+				continue
+			}
+			out, err := fn(fv, false)
+			if err != nil {
+				return nil, err
+			}
+			n := 0
+			switch s := out.(type) {
+			case string:
+				n = len(s)
+			case []byte:
+				n = len(s)
+			}
+			if n > max {
+				max = n
+			}
+		}
+		if max > maxCFieldLen {
+			max = maxCFieldLen
+		}
+		lens[f.name] = max
+	}
+	return lens, nil
+}
+
+// FieldSpecs returns the DBF field definitions of the struct v, or of the
+// element type if v is a struct slice or array, parsed from the tag named tag.
+// An empty tag means "dbf". The definitions can be passed to AddField
+// before CreateFile.
+//
+// Fields whose tag omits len get the default length of their type:
+// 254 for "C", 10 for "N" and 12 with 2 decimals for "F".
+func FieldSpecs(v interface{}, tag string) ([]FieldSpec, error) {
+	typ := reflect.TypeOf(v)
+	if typ == nil {
+		return nil, &InvalidMarshalError{}
+	}
+	typ = walkType(typ)
+	if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = walkType(typ.Elem())
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, &InvalidMarshalError{Type: reflect.TypeOf(v)}
+	}
+	if tag == "" {
+		tag = defaultTag
+	}
+	fields := cachedFields(typeKey{tag, typ})
+	specs := make([]FieldSpec, 0, len(fields))
+	for _, f := range fields {
+		if err := checkTagType(f); err != nil {
+			return nil, err
+		}
+		length, dec := f.tag.fieldLen()
+		fm, err := NewField(f.name, f.tag.dbfType, length, dec)
+		if err != nil {
+			return nil, fmt.Errorf("xbase: field %q: %w", f.fieldName, err)
+		}
+		specs = append(specs, fm.spec())
+	}
+	return specs, nil
+}
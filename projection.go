@@ -0,0 +1,102 @@
+package xbase
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Projection reads the values of some fields of the records of a DBF file,
+// decoding only those fields. It has its own position and doesn't move the
+// cursor of the XBase.
+//
+//	proj, err := db.Project("NAME", "PRICE")
+//	for proj.Next() {
+//		values := proj.Values()
+//	}
+//	err = proj.Err()
+type Projection struct {
+	db     *XBase
+	fields []*field
+	recNo  int64
+	buf    []byte
+	values []string
+	err    error
+}
+
+// Project returns a Projection reading the fields named fieldNames, in the
+// given order.
+func (db *XBase) Project(fieldNames ...string) (*Projection, error) {
+	if len(fieldNames) == 0 {
+		return nil, fmt.Errorf("xbase: Project: no fields")
+	}
+	p := &Projection{
+		db:     db,
+		fields: make([]*field, 0, len(fieldNames)),
+		recNo:  db.firstRecNo() - 1,
+		buf:    make([]byte, len(db.buffer)),
+		values: make([]string, len(fieldNames)),
+	}
+	for _, name := range fieldNames {
+		f, err := db.fieldByName("Project", name)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.checkBuffer(p.buf); err != nil {
+			return nil, fmt.Errorf("xbase: Project: field %q: %w", f.name(), err)
+		}
+		p.fields = append(p.fields, f)
+	}
+	return p, nil
+}
+
+// Next reads the next record and decodes the projected fields. It returns
+// false after the last record or on error, see Err.
+func (p *Projection) Next() bool {
+	if p.err != nil || p.recNo >= p.db.recCount() {
+		return false
+	}
+	p.recNo++
+	if p.err = p.read(); p.err != nil {
+		return false
+	}
+	for i, f := range p.fields {
+		s, err := f.stringValue(p.buf, p.db.decoder)
+		if err != nil {
+			p.err = fmt.Errorf("xbase: Projection: record %d field %q: %w", p.recNo, f.name(), err)
+			return false
+		}
+		p.values[i] = strings.TrimSpace(s)
+	}
+	return true
+}
+
+func (p *Projection) read() error {
+	defer p.db.lock()()
+	if err := p.db.seekRecord(p.recNo); err != nil {
+		return err
+	}
+	_, err := io.ReadFull(p.db.rws, p.buf)
+	return err
+}
+
+// Values returns the trimmed string values of the projected fields of the
+// record read by Next. The slice is reused by the next call to Next.
+func (p *Projection) Values() []string {
+	return p.values
+}
+
+// RecNo returns the number of the record read by Next.
+func (p *Projection) RecNo() int64 {
+	return p.recNo
+}
+
+// Deleted reports whether the record read by Next is marked as deleted.
+func (p *Projection) Deleted() bool {
+	return p.buf[0] == '*'
+}
+
+// Err returns the error that stopped Next, if any.
+func (p *Projection) Err() error {
+	return p.err
+}
@@ -0,0 +1,68 @@
+package xbase
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type schema struct {
+	FileType byte          `json:"fileType"`
+	CodePage int           `json:"codePage"`
+	ModDate  string        `json:"modDate"`
+	Fields   []schemaField `json:"fields"`
+}
+
+type schemaField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Len  int    `json:"len"`
+	Dec  int    `json:"dec"`
+}
+
+const schemaDateLayout = "2006-01-02"
+
+// ExportSchema returns the structure of the DBF file as JSON: the file type,
+// the code page, the modification date and the fields with their types,
+// lengths and decimals.
+func (db *XBase) ExportSchema() ([]byte, error) {
+	s := schema{
+		FileType: db.FileType(),
+		CodePage: db.CodePage(),
+		Fields:   make([]schemaField, 0, len(db.fields)),
+	}
+	if d := db.ModDate(); !d.IsZero() {
+		s.ModDate = d.Format(schemaDateLayout)
+	}
+	for _, f := range db.fields {
+		s.Fields = append(s.Fields, schemaField{Name: f.name(), Type: string(f.Type), Len: int(f.Len), Dec: int(f.Dec)})
+	}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// ImportSchema parses a schema produced by ExportSchema and returns its
+// field definitions, which can be passed to AddField before CreateFile.
+func ImportSchema(b []byte) ([]FieldSpec, error) {
+	var s schema
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("xbase: ImportSchema: %w", err)
+	}
+	if s.ModDate != "" {
+		if _, err := time.Parse(schemaDateLayout, s.ModDate); err != nil {
+			return nil, fmt.Errorf("xbase: ImportSchema: %w", err)
+		}
+	}
+	maxNameLen := maxFieldNameLen
+	if s.FileType == dbfId7 {
+		maxNameLen = maxLongFieldNameLen
+	}
+	specs := make([]FieldSpec, 0, len(s.Fields))
+	for _, sf := range s.Fields {
+		f, err := newField(sf.Name, sf.Type, sf.Len, sf.Dec, maxNameLen)
+		if err != nil {
+			return nil, fmt.Errorf("xbase: ImportSchema: field %q: %w", sf.Name, err)
+		}
+		specs = append(specs, f.spec())
+	}
+	return specs, nil
+}
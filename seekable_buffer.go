@@ -90,7 +90,7 @@ func (sb *SeekableBuffer) Read(p []byte) (n int, err error) {
 // Truncate either chops or extends the internal buffer.
 func (sb *SeekableBuffer) Truncate(size int64) (err error) {
 	sizeInt := int(size)
-	if sizeInt < len(sb.data)-1 {
+	if sizeInt <= len(sb.data) {
 		sb.data = sb.data[:sizeInt]
 	} else {
 		nd := make([]byte, sizeInt-len(sb.data))
@@ -0,0 +1,43 @@
+package xbase
+
+import "errors"
+
+// ErrStreamFinished is returned by StreamEncoder.Push after Finish was called.
+var ErrStreamFinished = errors.New("xbase: stream encoder is finished")
+
+// StreamEncoder appends values to a DBF one by one and defers writing the
+// header and the file end marker until Finish is called.
+type StreamEncoder struct {
+	db       *XBase
+	finished bool
+}
+
+// StreamEncoder returns a push style encoder for db.
+//
+//	enc := db.StreamEncoder()
+//	enc.Push(record)
+//	...
+//	err := enc.Finish()
+func (db *XBase) StreamEncoder() *StreamEncoder {
+	db.streaming = true
+	return &StreamEncoder{db: db}
+}
+
+// Push appends a value as Append does, but does not flush the header.
+func (s *StreamEncoder) Push(v interface{}) error {
+	if s.finished {
+		return ErrStreamFinished
+	}
+	return s.db.Append(v)
+}
+
+// Finish writes the header with the final record count and the file end marker.
+// The StreamEncoder can't be used after Finish.
+func (s *StreamEncoder) Finish() error {
+	if s.finished {
+		return ErrStreamFinished
+	}
+	s.finished = true
+	s.db.streaming = false
+	return s.db.Flush()
+}
@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const defaultTag = "dbf"
@@ -18,6 +19,8 @@ type tag struct {
 	dbfType   string
 	length    int //field length
 	decimal   int //decimal count
+	column    int //column number the field is bound to, 0 if bound by name
+	zeroPad   bool
 }
 
 func parseTag(tagname string, field reflect.StructField) (t tag) {
@@ -42,6 +45,8 @@ func parseTag(tagname string, field reflect.StructField) (t tag) {
 		switch opts[0] {
 		case "omitempty":
 			t.omitEmpty = true
+		case "zeropad":
+			t.zeroPad = true
 		case "inline":
 			if walkType(field.Type).Kind() == reflect.Struct {
 				t.inline = true
@@ -51,11 +56,19 @@ func parseTag(tagname string, field reflect.StructField) (t tag) {
 			t.length, _ = strconv.Atoi(opts[1])
 		case "dec":
 			t.decimal, _ = strconv.Atoi(opts[1])
+		case "index":
+			t.column, _ = strconv.Atoi(opts[1])
 		case "type":
 			//only 1 byte
 			t.dbfType = string(opts[1][0])
 		}
 	}
+	if t.dbfType == "" && isTextType(field.Type) {
+		t.dbfType = string(FieldType_Character)
+	}
+	if t.dbfType == "" && walkType(field.Type) == timeType {
+		t.dbfType = string(FieldType_Date)
+	}
 	if t.dbfType == "" {
 		switch field.Type.Kind() {
 		case reflect.String:
@@ -66,7 +79,71 @@ func parseTag(tagname string, field reflect.StructField) (t tag) {
 			t.dbfType = string(FieldType_Float)
 		case reflect.Bool:
 			t.dbfType = string(FieldType_Logical)
+		case reflect.Slice:
+			if field.Type.Elem().Kind() == reflect.Uint8 {
+				t.dbfType = string(FieldType_Character)
+			}
 		}
 	}
 	return
 }
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// fieldLen returns the length and the decimal count of the dbf field for t,
+// using the defaults of the field type if the tag omits len.
+func (t tag) fieldLen() (length, dec int) {
+	if t.length != 0 || t.dbfType == "" {
+		return t.length, t.decimal
+	}
+	return defaultFieldLen(t.dbfType[0], t.decimal)
+}
+
+// isTextType reports whether values of typ marshal themselves to text.
+func isTextType(typ reflect.Type) bool {
+	typ = walkType(typ)
+	if typ == timeType {
+		return false
+	}
+	ptr := reflect.PtrTo(typ)
+	return ptr.Implements(dbfMarshaler) || ptr.Implements(textMarshaler)
+}
+
+// checkTagType reports an error if the dbf type declared for f can't hold
+// values of the Go type of f. Character fields accept any type, numeric
+// fields integers and float fields floats.
+func checkTagType(f fieldDescription) error {
+	typ := walkType(f.baseType)
+	ok := true
+	switch f.tag.dbfType {
+	case string(FieldType_Binary):
+		switch typ.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+		default:
+			ok = false
+		}
+	case string(FieldType_Float):
+		ok = typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64
+	case string(FieldType_Numeric), string(FieldType_Autoincrement):
+		switch typ.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			ok = false
+		}
+	case string(FieldType_Logical):
+		ok = typ.Kind() == reflect.Bool
+	case string(FieldType_Date), string(FieldType_Timestamp):
+		ok = typ == timeType
+	}
+	if typ.Kind() == reflect.Interface {
+		// the dynamic type is only known at encoding time
+		ok = true
+	}
+	if !ok {
+		return &TagTypeError{Field: f.fieldName, Type: f.baseType, DBFType: f.tag.dbfType}
+	}
+	return nil
+}
@@ -5,8 +5,10 @@ import (
 )
 
 var (
-	_inferface = reflect.TypeOf((interface{})(nil))
+	_inferface = reflect.TypeOf((*interface{})(nil)).Elem()
 	_error     = reflect.TypeOf((*error)(nil)).Elem()
+	_string    = reflect.TypeOf("")
+	_bytes     = reflect.TypeOf([]byte(nil))
 )
 
 func valueType(v interface{}) (reflect.Type, error) {
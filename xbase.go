@@ -2,19 +2,26 @@ package xbase
 
 import (
 	"bytes"
+	"compress/gzip"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"math/big"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
 )
 
 const (
 	dbfId     byte = 0x03
+	dbfId7    byte = 0x04
 	headerEnd byte = 0x0D
 	fileEnd   byte = 0x1A
 )
@@ -22,6 +29,15 @@ const (
 const (
 	fieldSize  = 32
 	headerSize = 32
+	// dBase 7
+	fieldSize7  = 48
+	headerSize7 = 68
+)
+
+// File types set by SetFileType.
+const (
+	FileTypeDBase3 = dbfId
+	FileTypeDBase7 = dbfId7
 )
 
 // XBase is an util for DBF file.
@@ -41,21 +57,145 @@ type XBase struct {
 	isMod   bool
 	encoder *encoding.Encoder
 	decoder *encoding.Decoder
-	// 0: noop; 1: head; 2: field; 3:record
+	// readStep is readHeader until Read returned the header, then readRecords
 	readStep int
 	// 0: noop; 1: head; 2: field; 3:record
 	writeStep int
 
 	marshal   *Encoder
 	unmarshal *Decoder
+	// streaming defers the header flush until the stream is finished
+	streaming bool
+	// recAlign pads the record size to a multiple of it when greater than 1
+	recAlign int
+	// onUnmappable is the Recode policy for unrepresentable characters
+	onUnmappable OnUnmappable
+	// headerRecord holds the values of the first record if it is a header row
+	headerRecord []string
+	// fieldsMod indicates the field descriptors need to be rewritten
+	fieldsMod bool
+	// numPadding is the padding of written numeric values
+	numPadding NumericPadding
+	// transforms are applied to the values before they are set, by field index
+	transforms map[int]func(interface{}) (interface{}, error)
+	// lenient converts set values to the field type
+	lenient bool
+	// dropTrailing discards the data after the file end marker on rewrites
+	dropTrailing bool
+	// tail holds the trailing data read on the first append, written back
+	// after the appended records by Flush if tailPending
+	tail        []byte
+	tailPending bool
+	// readOnly rejects writes to the underlying seeker
+	readOnly bool
+	// logical holds the true and false bytes written to logical fields
+	logical [2]byte
+	// recNoField is the name of the field set to the record number on append
+	recNoField string
+	// tolerantHeader looks for the header after junk bytes, e.g. a BOM
+	tolerantHeader bool
+	// base is the offset of the header in the underlying seeker
+	base int64
+	// mu guards the cursor and the record buffer if not nil
+	mu *sync.RWMutex
+	// errMu guards err if not nil
+	errMu *sync.Mutex
+	// header7 holds the language driver name and reserved bytes of dBase 7
+	header7 [headerSize7 - headerSize]byte
+	// noEOFMarker omits the file end marker after the last record
+	noEOFMarker bool
+	// recomputeRecSize replaces a wrong record size in the header on open
+	recomputeRecSize bool
+	// recSizeDiff is the record size in the header minus the one computed
+	// from the field lengths when the file was opened
+	recSizeDiff int
+	// warnings holds the problems found on open
+	warnings []error
+	// overflowAsNull reads numeric values filled with asterisks as blank
+	overflowAsNull bool
+	// lastRead is the number of the record last returned by Read
+	lastRead int64
+	// preserveCase keeps the case of added field names
+	preserveCase bool
+	// writeBufSize is the size of the write buffer, 0 if writes aren't buffered
+	writeBufSize int
+	// wbuf buffers the writes to the seeker if not nil, it is then rws
+	wbuf *bufferedSeeker
+	// truncPolicy is the policy for character values longer than the field
+	truncPolicy TruncatePolicy
+}
+
+// An Option configures an XBase created by New or Open.
+type Option func(db *XBase)
+
+// tolerantHeaderWindow is the number of leading bytes searched for the header.
+const tolerantHeaderWindow = 16
+
+// WithTolerantHeader makes New and Open skip junk bytes before the header,
+// e.g. a UTF-8 BOM prepended by some exporters. Up to 16 bytes are skipped,
+// the offset found is returned by HeaderOffset.
+func WithTolerantHeader() Option {
+	return func(db *XBase) {
+		db.tolerantHeader = true
+	}
+}
+
+// WithThreadSafe guards GoTo, Read, Add, Save and the FieldValue methods with
+// a mutex, so an XBase can be shared by goroutines reading occasionally
+// without data races. Every call pays for the locking.
+//
+// It doesn't make sequences of calls atomic: another goroutine may move the
+// cursor between a GoTo and a FieldValue, and interleaved writes are not
+// safe. Use Clone to give each goroutine its own cursor.
+func WithThreadSafe() Option {
+	return func(db *XBase) {
+		db.mu = &sync.RWMutex{}
+		db.errMu = &sync.Mutex{}
+	}
+}
+
+// WithRecomputeRecSize makes New and Open trust the field definitions if the
+// record size in the header doesn't match the field lengths: the record size
+// is recomputed from them and written with the header when the file is
+// modified. Without it the mismatch is only reported by Warnings.
+func WithRecomputeRecSize() Option {
+	return func(db *XBase) {
+		db.recomputeRecSize = true
+	}
+}
+
+// WithWriteBuffer buffers the writes to the file in memory up to size bytes,
+// e.g. to append many records with fewer syscalls. The buffer is written
+// out by Flush and Close, before reads and before writes elsewhere in the
+// file.
+func WithWriteBuffer(size int) Option {
+	return func(db *XBase) {
+		db.writeBufSize = size
+	}
+}
+
+// WithTruncatePolicy sets how character values longer than the field are
+// written. The default TruncateError fails, TruncateCharBoundary cuts the
+// value between characters of the code page, so that a multi-byte character
+// isn't split.
+func WithTruncatePolicy(p TruncatePolicy) Option {
+	return func(db *XBase) {
+		db.truncPolicy = p
+	}
 }
 
 // New creates a XBase object to work with a DBF file and an error if any.
-func New(seeker io.ReadWriteSeeker) (*XBase, error) {
+func New(seeker io.ReadWriteSeeker, opts ...Option) (*XBase, error) {
 	db := XBase{
 		header: newHeader(),
 		rws:    seeker,
 	}
+	for _, opt := range opts {
+		opt(&db)
+	}
+	if err := db.setSeeker(seeker); err != nil {
+		return nil, err
+	}
 	if db.rws != nil {
 		// may be empty
 		err := db.prepareReader()
@@ -66,26 +206,133 @@ func New(seeker io.ReadWriteSeeker) (*XBase, error) {
 	return &db, nil
 }
 
+// MustNew is like New but panics if the DBF can't be read.
+// It simplifies creating a new DBF: MustNew(nil).
+func MustNew(seeker io.ReadWriteSeeker) *XBase {
+	db, err := New(seeker)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// NewFromReader creates a XBase object from a DBF file read from r.
+// The file is kept in memory.
+func NewFromReader(r io.Reader) (*XBase, error) {
+	db := &XBase{header: newHeader()}
+	if _, err := db.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
 func (db *XBase) prepareReader() (err error) {
-	if err = db.header.read(db.rws); err != nil {
+	if db.tolerantHeader {
+		err = db.findHeader()
+	} else {
+		err = db.header.read(db.rws)
+	}
+	if err != nil {
 		return
 	}
-
-	if err = db.readFields(db.rws); err != nil {
+	if db.header.level7() {
+		if _, err = io.ReadFull(db.rws, db.header7[:]); err != nil {
+			return
+		}
+		err = db.readFields7(db.rws)
+	} else {
+		err = db.readFields(db.rws)
+	}
+	if err != nil {
 		return
 	}
+	db.checkRecSize()
 	db.makeBuf()
 	db.SetCodePage(db.CodePage())
 	return
 }
 
+// checkRecSize compares the record size in the header to the field lengths.
+func (db *XBase) checkRecSize() {
+	size := db.calcRecSize()
+	db.recSizeDiff = int(db.header.RecSize) - int(size)
+	if db.recSizeDiff == 0 {
+		return
+	}
+	if db.recomputeRecSize {
+		db.warnings = append(db.warnings, fmt.Errorf("xbase: record size %d in header recomputed to %d from field lengths", db.header.RecSize, size))
+		db.header.RecSize = size
+		return
+	}
+	if db.recSizeDiff == -1 {
+		// some writers leave out the deletion flag, the records still have it
+		db.warnings = append(db.warnings, fmt.Errorf("xbase: record size %d in header, want %d from field lengths: the deletion flag may be left out, open with WithRecomputeRecSize to correct it", db.header.RecSize, size))
+		return
+	}
+	db.warnings = append(db.warnings, fmt.Errorf("xbase: record size %d in header, want %d from field lengths", db.header.RecSize, size))
+}
+
+// RecSizeDiscrepancy returns the record size in the header of the opened
+// file minus the size computed from the field lengths, 0 if they match.
+// A discrepancy of -1 usually means the writer left out the deletion flag,
+// the records are then read shifted unless the file is opened with
+// WithRecomputeRecSize.
+func (db *XBase) RecSizeDiscrepancy() int {
+	return db.recSizeDiff
+}
+
+// Warnings returns the problems found when the DBF file was opened which
+// don't prevent reading it, e.g. a record size in the header that doesn't
+// match the field lengths.
+func (db *XBase) Warnings() []error {
+	return db.warnings
+}
+
+// findHeader reads the header at the first offset within the tolerant window
+// where a plausible one starts.
+func (db *XBase) findHeader() error {
+	var first error
+	for off := int64(0); off <= tolerantHeaderWindow; off++ {
+		if _, err := db.rws.Seek(off, io.SeekStart); err != nil {
+			return err
+		}
+		h := &header{}
+		err := h.read(db.rws)
+		if err == nil && !h.plausible() {
+			err = fmt.Errorf("not DBF file")
+		}
+		if err == nil {
+			db.header = h
+			db.base = off
+			return nil
+		}
+		if first == nil {
+			first = err
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+	}
+	return first
+}
+
+// HeaderOffset returns the offset of the header in the file, non zero if
+// junk bytes were skipped by WithTolerantHeader.
+func (db *XBase) HeaderOffset() int64 {
+	return db.base
+}
+
 // CreateFile creates a new file in DBF format.
 // If a file with that name exists, it will be overwritten.
 func (db *XBase) CreateFile(name string) (err error) {
 	if err = db.checkFields(); err != nil {
 		return
 	}
-	if db.rws, err = os.Create(name); err != nil {
+	f, err := os.Create(name)
+	if err != nil {
+		return
+	}
+	if err = db.setSeeker(f); err != nil {
 		return
 	}
 	if err = db.writeHeader(); err != nil {
@@ -99,43 +346,273 @@ func (db *XBase) CreateFile(name string) (err error) {
 	return
 }
 
+// setSeeker sets the seeker of the file, wrapped in a write buffer if
+// WithWriteBuffer was given.
+func (db *XBase) setSeeker(rws io.ReadWriteSeeker) (err error) {
+	db.rws, db.wbuf = rws, nil
+	if rws == nil || db.writeBufSize <= 0 {
+		return nil
+	}
+	if db.wbuf, err = newBufferedSeeker(rws, db.writeBufSize); err != nil {
+		return err
+	}
+	db.rws = db.wbuf
+	return nil
+}
+
+// seeker returns the seeker of the file without the write buffer.
+func (db *XBase) seeker() io.ReadWriteSeeker {
+	if db.wbuf != nil {
+		return db.wbuf.rws
+	}
+	return db.rws
+}
+
+// flushWrites writes the buffered writes to the seeker.
+func (db *XBase) flushWrites() error {
+	if db.wbuf == nil {
+		return nil
+	}
+	return db.wbuf.Flush()
+}
+
+// canTruncate reports whether the seeker supports truncation, like *os.File.
+func (db *XBase) canTruncate() bool {
+	_, ok := db.seeker().(interface{ Truncate(size int64) error })
+	return ok
+}
+
+// truncate changes the size of the file, the seeker must support it.
+func (db *XBase) truncate(size int64) error {
+	if err := db.flushWrites(); err != nil {
+		return err
+	}
+	if err := db.seeker().(interface{ Truncate(size int64) error }).Truncate(size); err != nil {
+		return err
+	}
+	if db.wbuf != nil {
+		db.wbuf.size = -1
+	}
+	return nil
+}
+
 // Open opens an existing DBF file.
-func Open(name string, readOnly bool) (db *XBase, err error) {
+func Open(name string, readOnly bool, opts ...Option) (db *XBase, err error) {
 	var f *os.File
 	if readOnly {
 		f, err = os.Open(name)
 	} else {
 		f, err = os.OpenFile(name, os.O_RDWR, 0666)
 	}
-	db, err = New(f)
+	db, err = New(f, opts...)
 	if err != nil {
 		return
 	}
+	db.readOnly = readOnly
+	if !readOnly && db.HasMDX() {
+		db.warnings = append(db.warnings, ErrIndexNotMaintained)
+	}
 	return db, nil
 }
 
 // Flush commit changes to file
 func (db *XBase) Flush() (err error) {
 	if db.isMod {
+		if db.readOnly {
+			return ErrReadOnly
+		}
 		db.header.setModDate(time.Now())
+		if db.tailPending {
+			if err = db.writeTrailing(db.tail); err != nil {
+				return
+			}
+		}
 		if err = db.writeHeader(); err != nil {
 			return
 		}
+		if db.fieldsMod {
+			if err = db.writeFields(); err != nil {
+				return
+			}
+			db.fieldsMod = false
+		}
 		if err = db.writeFileEnd(); err != nil {
 			return
 		}
 		db.isMod = false
 	}
+	return db.flushWrites()
+}
+
+// Sync flushes pending changes like Flush and commits the file to stable
+// storage if the seeker supports it, like *os.File. It does nothing more for
+// other seekers.
+func (db *XBase) Sync() error {
+	if err := db.Flush(); err != nil {
+		return err
+	}
+	if s, ok := db.seeker().(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// WriteTo implements io.WriterTo. It flushes pending changes and writes the
+// whole DBF file to w.
+func (db *XBase) WriteTo(w io.Writer) (n int64, err error) {
+	if err = db.Flush(); err != nil {
+		return
+	}
+	if _, err = db.rws.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	return io.Copy(w, db.rws)
+}
+
+// ReadFrom implements io.ReaderFrom. It reads a whole DBF file from r into
+// memory and replaces the content of db with it. The previous file is
+// flushed and closed, the options and the settings of db are kept.
+func (db *XBase) ReadFrom(r io.Reader) (n int64, err error) {
+	b, err := io.ReadAll(r)
+	n = int64(len(b))
+	if err != nil {
+		return
+	}
+	nb, err := New(&SeekableBuffer{data: b}, db.openOptions()...)
+	if err != nil {
+		return
+	}
+	if err = db.Close(); err != nil {
+		return
+	}
+	nb.copySettings(db)
+	*db = *nb
 	return
 }
 
+// OpenGz opens a gzip compressed DBF file read-only. The file is decompressed
+// into memory, writes fail with ErrReadOnly.
+func OpenGz(name string) (*XBase, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	db, err := NewFromReader(zr)
+	if err != nil {
+		return nil, err
+	}
+	db.readOnly = true
+	return db, nil
+}
+
+// OpenFS opens the DBF file name of fsys read-only, e.g. a table embedded
+// by go:embed. The file is read into memory, writes fail with ErrReadOnly.
+func OpenFS(fsys fs.FS, name string, opts ...Option) (*XBase, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	db, err := New(NewSeekableBufferWithBytes(b), opts...)
+	if err != nil {
+		return nil, err
+	}
+	db.readOnly = true
+	return db, nil
+}
+
+// Clone returns an independent read cursor on the same DBF file with its
+// own record buffer and position, e.g. for concurrent read workers.
+// A file is reopened read-only, a SeekableBuffer is copied. Pending changes
+// are flushed first. Writes still require exclusive access to the file.
+// The clone has the options and the settings of db.
+func (db *XBase) Clone() (*XBase, error) {
+	if err := db.Flush(); err != nil {
+		return nil, err
+	}
+	var rws io.ReadWriteSeeker
+	switch s := db.seeker().(type) {
+	case *os.File:
+		f, err := os.Open(s.Name())
+		if err != nil {
+			return nil, err
+		}
+		rws = f
+	case *SeekableBuffer:
+		rws = NewSeekableBufferWithBytes(s.Bytes())
+	default:
+		return nil, fmt.Errorf("xbase: Clone: can't reopen %T", db.seeker())
+	}
+	c, err := New(rws, db.openOptions()...)
+	if err != nil {
+		if ioc, ok := rws.(io.Closer); ok {
+			ioc.Close()
+		}
+		return nil, err
+	}
+	c.copySettings(db)
+	if db.headerRecord != nil {
+		c.headerRecord = append([]string(nil), db.headerRecord...)
+	}
+	if db.transforms != nil {
+		c.transforms = make(map[int]func(interface{}) (interface{}, error), len(db.transforms))
+		for i, fn := range db.transforms {
+			c.transforms[i] = fn
+		}
+	}
+	c.recNoField = db.recNoField
+	return c, nil
+}
+
+// openOptions returns the Options db was configured with, which take effect
+// when a file is opened.
+func (db *XBase) openOptions() []Option {
+	var opts []Option
+	if db.tolerantHeader {
+		opts = append(opts, WithTolerantHeader())
+	}
+	if db.mu != nil {
+		opts = append(opts, WithThreadSafe())
+	}
+	if db.recomputeRecSize {
+		opts = append(opts, WithRecomputeRecSize())
+	}
+	if db.writeBufSize > 0 {
+		opts = append(opts, WithWriteBuffer(db.writeBufSize))
+	}
+	if db.truncPolicy != TruncateError {
+		opts = append(opts, WithTruncatePolicy(db.truncPolicy))
+	}
+	return opts
+}
+
+// copySettings copies the settings of src made by its setters which don't
+// depend on the fields of the file.
+func (db *XBase) copySettings(src *XBase) {
+	db.recAlign = src.recAlign
+	db.numPadding = src.numPadding
+	db.onUnmappable = src.onUnmappable
+	db.lenient = src.lenient
+	db.logical = src.logical
+	db.overflowAsNull = src.overflowAsNull
+	db.preserveCase = src.preserveCase
+	db.noEOFMarker = src.noEOFMarker
+	db.dropTrailing = src.dropTrailing
+	db.readOnly = src.readOnly
+}
+
 // Close closes a previously opened or created DBF file.
 func (db *XBase) Close() error {
 	if err := db.Flush(); err != nil {
 		return err
 	}
 
-	if ioc, ok := db.rws.(io.Closer); ok {
+	if ioc, ok := db.seeker().(io.Closer); ok {
 		return ioc.Close()
 	}
 	return nil
@@ -143,37 +620,71 @@ func (db *XBase) Close() error {
 
 // First positions the object to the first record.
 func (db *XBase) First() error {
-	return db.GoTo(1)
+	defer db.lock()()
+	return db.goTo(db.firstRecNo())
 }
 
 // Last positions the object to the last record.
 func (db *XBase) Last() error {
-	return db.GoTo(db.recCount())
+	defer db.lock()()
+	return db.goTo(db.recCount())
 }
 
 // Next positions the object to the next record.
 func (db *XBase) Next() error {
-	return db.GoTo(db.recordNum + 1)
+	defer db.lock()()
+	return db.goTo(db.recordNum + 1)
 }
 
 // Prev positions the object to the previous record.
 func (db *XBase) Prev() error {
-	return db.GoTo(db.recordNum - 1)
+	defer db.lock()()
+	if db.recordNum-1 < db.firstRecNo() {
+		return BOF
+	}
+	return db.goTo(db.recordNum - 1)
+}
+
+// NextDeleted positions the object to the next record marked as deleted.
+// It returns io.EOF and keeps the position if there is none.
+func (db *XBase) NextDeleted() error {
+	defer db.lock()()
+	start := db.recordNum + 1
+	if start < db.firstRecNo() {
+		start = db.firstRecNo()
+	}
+	for recNo := start; recNo <= db.recCount(); recNo++ {
+		deleted, err := db.deletedAt(recNo)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			return db.goTo(recNo)
+		}
+	}
+	return io.EOF
 }
 
 // RecNo returns the sequence number of the current record.
 // Numbering starts from 1.
 func (db *XBase) RecNo() int64 {
+	defer db.rlock()()
 	return db.recordNum
 }
 
 // EOF returns true if end of file is reached.
 func (db *XBase) EOF() bool {
+	defer db.rlock()()
+	return db.eof()
+}
+
+func (db *XBase) eof() bool {
 	return db.recordNum > db.recCount() || db.recCount() == 0
 }
 
 // BOF returns true if the beginning of the file is reached.
 func (db *XBase) BOF() bool {
+	defer db.rlock()()
 	return db.recordNum == 0 || db.recCount() == 0
 }
 
@@ -185,143 +696,482 @@ func (db *XBase) Fields() []string {
 	return hl
 }
 
-// Read() implement Reader
-func (db *XBase) Read() (val []string, err error) {
-	if db.recordNum != 0 {
-		// if has move record ptr
-		db.readStep = 2
+// SetFirstRecordIsHeader treats the first record as a header row holding the
+// display names of the fields. First and Prev skip that record and its values
+// are returned by HeaderNames.
+func (db *XBase) SetFirstRecordIsHeader(b bool) error {
+	db.headerRecord = nil
+	if !b {
+		return nil
 	}
-	switch db.readStep {
-	case 0:
-		//跳过header
-		val = db.Fields()
-		db.readStep = 2
-	case 2:
-		val, err = db.readRecord()
-		return
+	if db.recCount() == 0 {
+		return io.EOF
 	}
-	return
+	buf := make([]byte, len(db.buffer))
+	if err := db.readRecordAt(1, buf); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(db.fields))
+	for _, f := range db.fields {
+		s, err := f.stringValue(buf, db.decoder)
+		if err != nil {
+			return err
+		}
+		names = append(names, strings.TrimSpace(s))
+	}
+	db.headerRecord = names
+	return nil
 }
 
-// readRecord returns buffer string value
-func (db *XBase) readRecord() (val []string, err error) {
-	if db.err != nil {
-		return nil, db.err
+// HeaderNames returns the values of the header row if the first record is
+// treated as a header, otherwise the field names.
+func (db *XBase) HeaderNames() []string {
+	if db.headerRecord == nil {
+		return db.Fields()
 	}
-	var buffer = make([]byte, len(db.buffer))
-	copy(buffer, db.buffer)
+	names := make([]string, len(db.headerRecord))
+	copy(names, db.headerRecord)
+	return names
+}
+
+// FieldInfos returns the descriptions of all fields.
+func (db *XBase) FieldInfos() []FieldInfo {
+	infos := make([]FieldInfo, 0, len(db.fields))
 	for _, f := range db.fields {
-		s := strings.TrimSpace(string(f.buffer(buffer)))
-		val = append(val, s)
+		infos = append(infos, f.info())
 	}
-	err = db.Next()
-	return
+	return infos
 }
 
-// DecodeRecord decode current row to a struct
-func (db *XBase) DecodeRecord(dst interface{}) (err error) {
-	if db.unmarshal == nil {
-		db.unmarshal, err = NewDecoder(db, db.Fields()...)
-		if err != nil {
-			return
+// Columns returns the descriptions of all fields with their offsets in
+// the record, so the raw record bytes can be sliced by the caller.
+func (db *XBase) Columns() []Column {
+	cols := make([]Column, 0, len(db.fields))
+	for i, f := range db.fields {
+		cols = append(cols, Column{
+			Name:    f.name(),
+			Type:    f.Type,
+			Len:     int(f.Len),
+			Dec:     int(f.Dec),
+			Offset:  int(f.Offset),
+			Ordinal: i + 1,
+		})
+	}
+	return cols
+}
+
+// FieldsOfType returns the descriptions of the fields of type typ, e.g. 'N'.
+func (db *XBase) FieldsOfType(typ byte) []FieldInfo {
+	var infos []FieldInfo
+	for _, f := range db.fields {
+		if f.Type == typ {
+			infos = append(infos, f.info())
 		}
 	}
-	return db.unmarshal.Decode(dst)
+	return infos
 }
 
-func (db *XBase) Write(input []interface{}) (err error) {
-	if len(db.fields) != 0 {
-		// has load field
-		db.writeStep = 2
+// Preview returns the fields and the trimmed string values of the first n
+// not deleted records. It doesn't move the cursor.
+func (db *XBase) Preview(n int) ([]FieldInfo, [][]string, error) {
+	if n < 0 {
+		return nil, nil, fmt.Errorf("xbase: Preview: negative count %d", n)
 	}
-	switch db.writeStep {
-	case 0:
-		count := input[0].(int)
+	rows := make([][]string, 0, n)
+	err := db.scan(func(recNo int64, buf []byte) error {
+		if len(rows) >= n {
+			return io.EOF
+		}
+		if recNo < db.firstRecNo() || buf[0] == '*' {
+			return nil
+		}
+		row, err := db.stringValues(buf)
 		if err != nil {
 			return err
 		}
-		db.header.setFieldCount(count)
-		rd := bytes.NewBuffer(input[1].([]byte))
-		if err = db.readFields(rd); err != nil {
-			return err
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	return db.FieldInfos(), rows, nil
+}
+
+// ReadWindow returns the trimmed string values of up to limit not deleted
+// records after skipping offset not deleted records, e.g. for pagination.
+// Only the deletion flags of the skipped records are read. It doesn't move
+// the cursor.
+func (db *XBase) ReadWindow(offset, limit int64) ([][]string, error) {
+	if offset < 0 || limit < 0 {
+		return nil, fmt.Errorf("xbase: ReadWindow: negative offset %d or limit %d", offset, limit)
+	}
+	start := db.firstRecNo()
+	for ; start <= db.recCount(); start++ {
+		deleted, err := db.deletedAt(start)
+		if err != nil {
+			return nil, err
 		}
-		if err = db.writeHeader(); err != nil {
-			return err
+		if deleted {
+			continue
 		}
-		if err = db.writeFields(); err != nil {
-			return err
+		if offset == 0 {
+			break
 		}
-		db.makeBuf()
-		db.writeStep = 2
-	case 2:
-		if err := db.Add(); err != nil {
-			return err
+		offset--
+	}
+	rows := make([][]string, 0)
+	err := db.scanFrom(start, func(_ int64, buf []byte) error {
+		if int64(len(rows)) >= limit {
+			return io.EOF
 		}
-		for i, value := range input {
-			if value == nil {
-				//if value is nil in add
-				continue
-			}
-			if err = db.fields[i].setValue(db.buffer, value, db.encoder); err != nil {
-				return err
-			}
+		if buf[0] == '*' {
+			return nil
 		}
-		db.err = db.Save()
-		if db.err != nil {
-			return db.err
+		row, err := db.stringValues(buf)
+		if err != nil {
+			return err
 		}
-
-		return db.Flush()
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil && err != io.EOF {
+		return nil, err
 	}
-	return nil
+	return rows, nil
 }
 
-// FieldValueAsString returns the string value of the field of the current record.
-// Fields are numbered starting from 1.
-func (db *XBase) FieldValueAsString(fieldNo int) (val string) {
-	if db.err != nil {
-		return
+// stringValues returns the trimmed string values of the record in buf.
+func (db *XBase) stringValues(buf []byte) ([]string, error) {
+	row := make([]string, 0, len(db.fields))
+	for _, f := range db.fields {
+		s, err := f.stringValue(buf, db.decoder)
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, strings.TrimSpace(s))
 	}
-	defer db.wrapFieldError("FieldValueAsString", fieldNo)
-	var err error
-	if val, err = db.fieldByNo(fieldNo).stringValue(db.buffer, db.decoder); err != nil {
+	return row, nil
+}
+
+const (
+	readHeader = iota
+	readRecords
+)
+
+// Read implements Reader. The first call returns the header, see HeaderNames,
+// whatever the position of the cursor. The following calls return the
+// trimmed string values of the current record and move to the next one,
+// starting from the first record if the cursor wasn't positioned. After the
+// last record Read returns io.EOF. Moving the cursor, e.g. by GoTo, makes the
+// next Read return the record at the new position.
+//
+// NewDecoder doesn't read the header if the field names are given.
+func (db *XBase) Read() (val []string, err error) {
+	defer db.lock()()
+	if db.readStep == readHeader {
+		db.readStep = readRecords
+		return db.HeaderNames(), nil
+	}
+	return db.readRecord()
+}
+
+// skipHeader makes Read return the records from the first call on.
+func (db *XBase) skipHeader() {
+	defer db.lock()()
+	db.readStep = readRecords
+}
+
+// readRecord returns buffer string value
+func (db *XBase) readRecord() (val []string, err error) {
+	if db.Error() != nil {
+		return nil, db.Error()
+	}
+	if db.recordNum == 0 {
+		if err = db.goTo(db.firstRecNo()); err != nil {
+			return nil, err
+		}
+	}
+	if db.eof() {
+		return nil, io.EOF
+	}
+	db.lastRead = db.recordNum
+	if val, err = db.recordValues(); err != nil {
+		return nil, err
+	}
+	if err = db.goTo(db.recordNum + 1); errors.Is(err, io.EOF) {
+		// move past the last record, the next read returns io.EOF
+		db.recordNum = db.recCount() + 1
+		err = nil
+	}
+	return
+}
+
+// recordValues returns the trimmed string values of the current record.
+func (db *XBase) recordValues() ([]string, error) {
+	for _, f := range db.fields {
+		if err := f.checkBuffer(db.buffer); err != nil {
+			return nil, err
+		}
+	}
+	return db.stringValues(db.buffer)
+}
+
+// FieldValues returns the trimmed string values of the current record like
+// Read, but without moving to the next record.
+func (db *XBase) FieldValues() []string {
+	defer db.rlock()()
+	if db.Error() != nil {
+		return nil
+	}
+	val, err := db.recordValues()
+	if err != nil {
+		db.setErr(fmt.Errorf("xbase: FieldValues: %w", err))
+		return nil
+	}
+	return val
+}
+
+// FieldPos returns the position of the field fieldIndex, numbered from 0,
+// of the record last returned by Read: line is the record number and column
+// the field number. Decoder uses it to locate decoding errors.
+func (db *XBase) FieldPos(fieldIndex int) (line, column int) {
+	return int(db.lastRead), fieldIndex + 1
+}
+
+// DecodeRecord decode current row to a struct
+func (db *XBase) DecodeRecord(dst interface{}) (err error) {
+	if db.unmarshal == nil {
+		db.unmarshal, err = NewDecoder(db, db.Fields()...)
+		if err != nil {
+			return
+		}
+	}
+	return db.unmarshal.Decode(dst)
+}
+
+func (db *XBase) Write(input []interface{}) (err error) {
+	if len(db.fields) != 0 {
+		// has load field
+		db.writeStep = 2
+	}
+	switch db.writeStep {
+	case 0:
+		count := input[0].(int)
+		if err != nil {
+			return err
+		}
+		db.header.setFieldCount(count)
+		rd := bytes.NewBuffer(input[1].([]byte))
+		if err = db.readFields(rd); err != nil {
+			return err
+		}
+		if err = db.writeHeader(); err != nil {
+			return err
+		}
+		if err = db.writeFields(); err != nil {
+			return err
+		}
+		db.makeBuf()
+		db.isMod = true
+		db.writeStep = 2
+	case 2:
+		if err := db.Add(); err != nil {
+			return err
+		}
+		for i, value := range input {
+			if value == nil {
+				//if value is nil in add
+				continue
+			}
+			if err = db.setValue(i, value); err != nil {
+				// drop the record so that the next Write can add one
+				db.isAdd = false
+				return err
+			}
+		}
+		if err = db.Save(); err != nil {
+			db.setErr(err)
+			return err
+		}
+		if db.streaming {
+			return nil
+		}
+		return db.Flush()
+	}
+	return nil
+}
+
+// FieldValueAsString returns the string value of the field of the current record.
+// Fields are numbered starting from 1.
+func (db *XBase) FieldValueAsString(fieldNo int) (val string) {
+	defer db.rlock()()
+	if db.Error() != nil {
+		return
+	}
+	defer db.wrapFieldError("FieldValueAsString", fieldNo)
+	var err error
+	if val, err = db.fieldByNo(fieldNo).stringValue(db.buffer, db.decoder); err != nil {
+		panic(err)
+	}
+	return
+}
+
+// FieldValue returns the value of the field of the current record as the Go
+// type matching the field type: string for character fields, int64 for
+// numeric fields without decimals, float64 for other numeric fields, bool for
+// logical and time.Time for date fields. Blank numeric and date values are nil.
+// Fields are numbered starting from 1.
+func (db *XBase) FieldValue(fieldNo int) (val interface{}) {
+	defer db.rlock()()
+	if db.Error() != nil {
+		return
+	}
+	defer db.wrapFieldError("FieldValue", fieldNo)
+	var err error
+	val, err = db.fieldByNo(fieldNo).value(db.buffer, db.decoder)
+	if err = db.overflowErr(err); err != nil {
 		panic(err)
 	}
 	return
 }
 
+// RecordMap returns the values of the current record keyed by field name,
+// as returned by FieldValue.
+func (db *XBase) RecordMap() (map[string]interface{}, error) {
+	defer db.rlock()()
+	if db.Error() != nil {
+		return nil, db.Error()
+	}
+	return db.recordMap(db.buffer)
+}
+
+// ReadAllMaps returns the values of all not deleted records as maps, as
+// returned by RecordMap. It doesn't move the cursor.
+func (db *XBase) ReadAllMaps() ([]map[string]interface{}, error) {
+	var maps []map[string]interface{}
+	err := db.scan(func(recNo int64, buf []byte) error {
+		if recNo < db.firstRecNo() || buf[0] == '*' {
+			return nil
+		}
+		m, err := db.recordMap(buf)
+		if err != nil {
+			return err
+		}
+		maps = append(maps, m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return maps, nil
+}
+
+func (db *XBase) recordMap(buf []byte) (map[string]interface{}, error) {
+	m := make(map[string]interface{}, len(db.fields))
+	for _, f := range db.fields {
+		v, err := f.value(buf, db.decoder)
+		if err != nil {
+			return nil, fmt.Errorf("xbase: field %q: %w", f.name(), err)
+		}
+		m[f.name()] = v
+	}
+	return m, nil
+}
+
 // FieldValueAsInt returns the integer value of the field of the current record.
 // Field type must be numeric ("N"). Fields are numbered starting from 1.
 func (db *XBase) FieldValueAsInt(fieldNo int) (val int64) {
-	if db.err != nil {
+	defer db.rlock()()
+	if db.Error() != nil {
 		return
 	}
 	defer db.wrapFieldError("FieldValueAsInt", fieldNo)
 	var err error
-	if val, err = db.fieldByNo(fieldNo).intValue(db.buffer); err != nil {
+	val, err = db.fieldByNo(fieldNo).intValue(db.buffer)
+	if err = db.overflowErr(err); err != nil {
 		panic(err)
 	}
 	return
 }
 
 // FieldValueAsFloat returns the float value of the field of the current record.
-// Field type must be numeric ("N"). Fields are numbered starting from 1.
+// Field type must be numeric ("N"), float ("F") or binary double ("B"). Fields are numbered starting from 1.
 func (db *XBase) FieldValueAsFloat(fieldNo int) (val float64) {
-	if db.err != nil {
+	defer db.rlock()()
+	if db.Error() != nil {
 		return
 	}
 	defer db.wrapFieldError("FieldValueAsFloat", fieldNo)
 	var err error
-	if val, err = db.fieldByNo(fieldNo).floatValue(db.buffer); err != nil {
+	val, err = db.fieldByNo(fieldNo).floatValue(db.buffer)
+	if err = db.overflowErr(err); err != nil {
+		panic(err)
+	}
+	return
+}
+
+// FieldValueAsDecimalString returns the exact stored value of the field of
+// the current record as a decimal string, without going through float64,
+// e.g. "123.45" or "-0.01". A blank value is "".
+// Field type must be numeric ("N") or float ("F"). Fields are numbered
+// starting from 1.
+func (db *XBase) FieldValueAsDecimalString(fieldNo int) (val string) {
+	defer db.rlock()()
+	if db.Error() != nil {
+		return
+	}
+	defer db.wrapFieldError("FieldValueAsDecimalString", fieldNo)
+	var err error
+	val, err = db.fieldByNo(fieldNo).decimalString(db.buffer)
+	if err = db.overflowErr(err); err != nil {
+		panic(err)
+	}
+	return
+}
+
+// FieldValueAsRat returns the exact value of the field of the current record
+// as a rational number. A blank value is nil.
+// Field type must be numeric ("N") or float ("F"). Fields are numbered
+// starting from 1.
+func (db *XBase) FieldValueAsRat(fieldNo int) (val *big.Rat) {
+	defer db.rlock()()
+	if db.Error() != nil {
+		return
+	}
+	defer db.wrapFieldError("FieldValueAsRat", fieldNo)
+	s, err := db.fieldByNo(fieldNo).decimalString(db.buffer)
+	if err = db.overflowErr(err); err != nil {
 		panic(err)
 	}
+	if s == "" {
+		return nil
+	}
+	val, _ = new(big.Rat).SetString(s)
 	return
 }
 
+// SetOverflowAsNull sets whether numeric values filled with asterisks,
+// which dBase writes when a value doesn't fit the field, are read as blank
+// values instead of failing with ErrNumericOverflow: FieldValueAsInt and
+// FieldValueAsFloat return 0, FieldValue and FieldValueAsRat nil.
+func (db *XBase) SetOverflowAsNull(b bool) {
+	db.overflowAsNull = b
+}
+
+// overflowErr returns err unless it is a numeric overflow read as blank.
+func (db *XBase) overflowErr(err error) error {
+	if db.overflowAsNull && errors.Is(err, ErrNumericOverflow) {
+		return nil
+	}
+	return err
+}
+
 // FieldValueAsBool returns the boolean value of the field of the current record.
 // Field type must be logical ("L"). Fields are numbered starting from 1.
 func (db *XBase) FieldValueAsBool(fieldNo int) (val bool) {
-	if db.err != nil {
+	defer db.rlock()()
+	if db.Error() != nil {
 		return
 	}
 	defer db.wrapFieldError("FieldValueAsBool", fieldNo)
@@ -335,7 +1185,8 @@ func (db *XBase) FieldValueAsBool(fieldNo int) (val bool) {
 // FieldValueAsDate returns the date value of the field of the current record.
 // Field type must be date ("D"). Fields are numbered starting from 1.
 func (db *XBase) FieldValueAsDate(fieldNo int) (d time.Time) {
-	if db.err != nil {
+	defer db.rlock()()
+	if db.Error() != nil {
 		return
 	}
 	defer db.wrapFieldError("FieldValueAsDate", fieldNo)
@@ -346,15 +1197,154 @@ func (db *XBase) FieldValueAsDate(fieldNo int) (d time.Time) {
 	return
 }
 
+// FieldValueAsTimestamp returns the timestamp value of the field of the current
+// record, stored as "YYYYMMDDHHMMSS" text. Blank values return zero time.
+// Field type must be character ("C"). Fields are numbered starting from 1.
+func (db *XBase) FieldValueAsTimestamp(fieldNo int) (t time.Time) {
+	defer db.rlock()()
+	if db.Error() != nil {
+		return
+	}
+	defer db.wrapFieldError("FieldValueAsTimestamp", fieldNo)
+	var err error
+	if t, err = db.fieldByNo(fieldNo).timestampValue(db.buffer); err != nil {
+		panic(err)
+	}
+	return
+}
+
+// FieldValueAsClock returns the time of day stored in the field of the
+// current record as "HH:MM:SS" or "HHMMSS" text, as the duration since
+// midnight. Blank values return 0.
+// Field type must be character ("C"). Fields are numbered starting from 1.
+func (db *XBase) FieldValueAsClock(fieldNo int) (d time.Duration) {
+	defer db.rlock()()
+	if db.Error() != nil {
+		return
+	}
+	defer db.wrapFieldError("FieldValueAsClock", fieldNo)
+	var err error
+	if d, err = db.fieldByNo(fieldNo).clockValue(db.buffer); err != nil {
+		panic(err)
+	}
+	return
+}
+
 // SetFieldValue sets the field value of the current record.
 // The value must match the field type.
 // To save the changes, you need to call the Save method.
 func (db *XBase) SetFieldValue(fieldNo int, value interface{}) {
-	if db.err != nil {
+	defer db.lock()()
+	if db.Error() != nil {
 		return
 	}
 	defer db.wrapFieldError("SetFieldValue", fieldNo)
-	if err := db.fieldByNo(fieldNo).setValue(db.buffer, value, db.encoder); err != nil {
+	db.fieldByNo(fieldNo)
+	if err := db.setValue(fieldNo-1, value); err != nil {
+		panic(err)
+	}
+}
+
+// SetFieldZeroPad sets whether numeric values written to the field are
+// padded with zeros after the sign, e.g. "00042", like SetNumericPadding
+// does for all fields. Fields are numbered starting from 1.
+func (db *XBase) SetFieldZeroPad(fieldNo int, zeroPad bool) {
+	if db.Error() != nil {
+		return
+	}
+	defer db.wrapFieldError("SetFieldZeroPad", fieldNo)
+	db.fieldByNo(fieldNo).zeroPadded = zeroPad
+}
+
+// SetFieldTransform sets fn to be applied to every value set to the field by
+// SetFieldValue or Write before it is stored, e.g. to normalize or validate
+// it. An error returned by fn fails the set. A nil fn removes the transform.
+// Fields are numbered starting from 1.
+func (db *XBase) SetFieldTransform(fieldNo int, fn func(interface{}) (interface{}, error)) {
+	if db.Error() != nil {
+		return
+	}
+	defer db.wrapFieldError("SetFieldTransform", fieldNo)
+	db.fieldByNo(fieldNo)
+	if fn == nil {
+		delete(db.transforms, fieldNo-1)
+		return
+	}
+	if db.transforms == nil {
+		db.transforms = make(map[int]func(interface{}) (interface{}, error))
+	}
+	db.transforms[fieldNo-1] = fn
+}
+
+// FieldValueAsBytes returns a copy of the raw bytes of the field of the
+// current record, as stored in the DBF file. For memo ("M") and general
+// ("G") fields it's the block number of the value in the memo file, which
+// isn't read: setting the bytes back with SetFieldValue and copying the memo
+// file along with the table preserves such values.
+// Fields are numbered starting from 1.
+func (db *XBase) FieldValueAsBytes(fieldNo int) (val []byte) {
+	defer db.rlock()()
+	if db.Error() != nil {
+		return
+	}
+	defer db.wrapFieldError("FieldValueAsBytes", fieldNo)
+	b, err := db.fieldByNo(fieldNo).buffer(db.buffer)
+	if err != nil {
+		panic(err)
+	}
+	val = make([]byte, len(b))
+	copy(val, b)
+	return
+}
+
+// RecordBytes returns a copy of the raw buffer of the current record.
+// The first byte is the deletion flag.
+func (db *XBase) RecordBytes() []byte {
+	defer db.rlock()()
+	b := make([]byte, len(db.buffer))
+	copy(b, db.buffer)
+	return b
+}
+
+// SetRecordBytes replaces the raw buffer of the current record with b, which
+// must be RecSize bytes long including the leading deletion flag.
+// To save the changes, you need to call the Save method.
+func (db *XBase) SetRecordBytes(b []byte) error {
+	defer db.lock()()
+	if len(b) != len(db.buffer) {
+		return fmt.Errorf("xbase: record bytes len %d, want %d", len(b), len(db.buffer))
+	}
+	copy(db.buffer, b)
+	return nil
+}
+
+// SetFieldTimestamp sets the field value of the current record to t formatted
+// as "YYYYMMDDHHMMSS", zero time is written as blanks.
+// Field type must be character ("C") of at least 14 bytes.
+// To save the changes, you need to call the Save method.
+func (db *XBase) SetFieldTimestamp(fieldNo int, t time.Time) {
+	defer db.lock()()
+	if db.Error() != nil {
+		return
+	}
+	defer db.wrapFieldError("SetFieldTimestamp", fieldNo)
+	if err := db.fieldByNo(fieldNo).setTimestampValue(db.buffer, t); err != nil {
+		panic(err)
+	}
+}
+
+// SetFieldClock sets the field value of the current record to the time of
+// day d, the duration since midnight, formatted as "HH:MM:SS", or as "HHMMSS"
+// if the field is shorter than 8 bytes. Fractions of a second are dropped.
+// Field type must be character ("C") of at least 6 bytes.
+// To save the changes, you need to call the Save method.
+func (db *XBase) SetFieldClock(fieldNo int, d time.Duration) {
+	defer db.lock()()
+	if db.Error() != nil {
+		return
+	}
+	defer db.wrapFieldError("SetFieldClock", fieldNo)
+	if err := db.fieldByNo(fieldNo).setClockValue(db.buffer, d); err != nil {
 		panic(err)
 	}
 }
@@ -362,6 +1352,7 @@ func (db *XBase) SetFieldValue(fieldNo int, value interface{}) {
 // Add adds a new empty record.
 // To save the changes, you need to call the Save method.
 func (db *XBase) Add() error {
+	defer db.lock()()
 	if db.isAdd {
 		return fmt.Errorf("current record is add model,Save it first")
 	}
@@ -371,7 +1362,11 @@ func (db *XBase) Add() error {
 }
 
 // Append an input value,auto call save
+// A map[string]interface{} is appended by AppendMap.
 func (db *XBase) Append(input interface{}) error {
+	if m, ok := input.(map[string]interface{}); ok {
+		return db.AppendMap(m)
+	}
 	if db.marshal == nil {
 		db.marshal = NewEncoder(db)
 		db.marshal.SetHeader(db.fields)
@@ -385,65 +1380,808 @@ func (db *XBase) Append(input interface{}) error {
 	return db.marshal.Encode(input)
 }
 
-// Save writes changes to the file.
-// Before calling it, all changes to the object were made
-// only in memory and will be lost when you move to another record
-// or close the file.
-func (db *XBase) Save() error {
-	if db.err != nil {
-		return db.err
+// AppendMap appends a new record with the values of m keyed by field name.
+// Fields not in m and nil values are left blank. Each value must be
+// assignable to its field like for SetFieldValue.
+func (db *XBase) AppendMap(m map[string]interface{}) error {
+	for name := range m {
+		if db.FieldNo(name) == 0 {
+			return fmt.Errorf("xbase: AppendMap: field %q not found", name)
+		}
+	}
+	if err := db.Add(); err != nil {
+		return err
+	}
+	for name, value := range m {
+		if value == nil {
+			continue
+		}
+		i := db.FieldNo(name) - 1
+		if err := db.setValue(i, value); err != nil {
+			db.isAdd = false
+			return fmt.Errorf("xbase: AppendMap: field %q: %w", db.fields[i].name(), err)
+		}
+	}
+	if err := db.Save(); err != nil {
+		return err
+	}
+	if db.streaming {
+		return nil
+	}
+	return db.Flush()
+}
+
+// AppendStruct appends v as a new record like Append, but defers writing the
+// header and the file end marker until Flush or Close.
+//
+// The record is written to the file right away and RecCount is updated in
+// memory only. If the process stops before Flush, the appended records are
+// present in the file but not counted by its header.
+func (db *XBase) AppendStruct(v interface{}) error {
+	streaming := db.streaming
+	db.streaming = true
+	defer func() { db.streaming = streaming }()
+	return db.Append(v)
+}
+
+// WriteAt sets the values of the record recNo, keyed by field name, and
+// saves it. If recNo is greater than RecCount, the file is extended with
+// blank records up to recNo-1 and the record is appended, e.g. to keep the
+// record numbers of a source system.
+func (db *XBase) WriteAt(recNo int64, values map[string]interface{}) error {
+	if recNo < 1 {
+		return fmt.Errorf("xbase: WriteAt: invalid record number %d", recNo)
 	}
-	// ignore to write header
 	if db.isAdd {
-		if err := db.seekRecord(db.recCount() + 1); err != nil {
-			return err
+		return fmt.Errorf("xbase: WriteAt: current record is in add mode, save it first")
+	}
+	for name := range values {
+		if db.FieldNo(name) == 0 {
+			return fmt.Errorf("xbase: WriteAt: field %q not found", name)
 		}
-		if err := db.fileWrite(db.buffer); err != nil {
+	}
+	for db.recCount() < recNo-1 {
+		if err := db.Add(); err != nil {
 			return err
 		}
-		db.recordNum++
-		db.header.RecCount++
-		db.isAdd = false
-	} else {
-		if db.recordNum == 0 {
-			return nil
-		}
-		//edit
-		if err := db.seekRecord(db.recordNum); err != nil {
+		if err := db.Save(); err != nil {
 			return err
 		}
-		if err := db.fileWrite(db.buffer); err != nil {
+	}
+	if recNo > db.recCount() {
+		if err := db.Add(); err != nil {
 			return err
 		}
+	} else if err := db.GoTo(recNo); err != nil {
+		return err
+	}
+	for name, value := range values {
+		i := db.FieldNo(name) - 1
+		if err := db.setValue(i, value); err != nil {
+			db.isAdd = false
+			return fmt.Errorf("xbase: WriteAt: field %q: %w", db.fields[i].name(), err)
+		}
 	}
-	db.isMod = true
-	return nil
+	return db.Save()
+}
+
+// A CopyOption configures CopyRecordsFrom.
+type CopyOption func(*copyOptions)
+
+type copyOptions struct {
+	skipDeleted bool
+	transcode   bool
+}
+
+// CopySkipDeleted makes CopyRecordsFrom skip the deleted records of the
+// source, they are copied with their deletion flag by default.
+func CopySkipDeleted() CopyOption {
+	return func(o *copyOptions) {
+		o.skipDeleted = true
+	}
+}
+
+// CopyTranscode makes CopyRecordsFrom transcode the character fields from
+// the code page of the source to the code page of db, which may then differ.
+func CopyTranscode() CopyOption {
+	return func(o *copyOptions) {
+		o.transcode = true
+	}
+}
+
+// CopyRecordsFrom appends the records of src for which filter returns true
+// to db and returns the number of copied records. The filter gets the
+// trimmed string values of a record, a nil filter copies all records.
+//
+// Both files must have the same fields (names, types, lengths and decimals)
+// and code page, the records are copied as raw bytes. With CopyTranscode
+// the character fields are transcoded instead and the code pages may
+// differ. The cursor of src isn't moved.
+func (db *XBase) CopyRecordsFrom(src *XBase, filter func(rec []string) bool, opts ...CopyOption) (copied int64, err error) {
+	var o copyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err = db.checkSameFields(src); err != nil {
+		return
+	}
+	if db.CodePage() != src.CodePage() && !o.transcode {
+		return 0, fmt.Errorf("xbase: CopyRecordsFrom: code page %d, source code page %d", db.CodePage(), src.CodePage())
+	}
+	if db.isAdd {
+		return 0, fmt.Errorf("current record is add model,Save it first")
+	}
+	err = src.scan(func(recNo int64, buf []byte) error {
+		if recNo < src.firstRecNo() || o.skipDeleted && buf[0] == '*' {
+			return nil
+		}
+		if filter != nil {
+			rec, err := src.stringValues(buf)
+			if err != nil {
+				return err
+			}
+			if !filter(rec) {
+				return nil
+			}
+		}
+		if err := db.Add(); err != nil {
+			return err
+		}
+		db.buffer[0] = buf[0]
+		for i, f := range db.fields {
+			if o.transcode && f.Type == FieldType_Character {
+				v, err := src.fields[i].stringValue(buf, src.decoder)
+				if err != nil {
+					return err
+				}
+				if err = f.setStringValue(db.buffer, v, db.encoder, db.truncPolicy); err != nil {
+					return fmt.Errorf("xbase: CopyRecordsFrom: record %d field %q: %w", recNo, f.name(), err)
+				}
+				continue
+			}
+			b, err := src.fields[i].buffer(buf)
+			if err != nil {
+				return fmt.Errorf("xbase: CopyRecordsFrom: record %d field %q: %w", recNo, f.name(), err)
+			}
+			if err = f.setBuffer(db.buffer, string(b)); err != nil {
+				return fmt.Errorf("xbase: CopyRecordsFrom: record %d field %q: %w", recNo, f.name(), err)
+			}
+		}
+		if err := db.Save(); err != nil {
+			return err
+		}
+		copied++
+		return nil
+	})
+	if err != nil {
+		db.isAdd = false
+		return copied, err
+	}
+	return copied, db.Flush()
+}
+
+// checkSameFields returns an error if the fields of src differ from db's.
+func (db *XBase) checkSameFields(src *XBase) error {
+	if len(db.fields) != len(src.fields) {
+		return fmt.Errorf("xbase: %d fields, source has %d", len(db.fields), len(src.fields))
+	}
+	for i, f := range db.fields {
+		g := src.fields[i]
+		if !strings.EqualFold(f.name(), g.name()) || f.Type != g.Type || f.Len != g.Len || f.Dec != g.Dec {
+			return fmt.Errorf("xbase: field %d %s %c(%d,%d), source has %s %c(%d,%d)",
+				i+1, f.name(), f.Type, f.Len, f.Dec, g.name(), g.Type, g.Len, g.Dec)
+		}
+	}
+	return nil
+}
+
+// Save writes changes to the file.
+// Before calling it, all changes to the object were made
+// only in memory and will be lost when you move to another record
+// or close the file.
+func (db *XBase) Save() error {
+	defer db.lock()()
+	if db.Error() != nil {
+		return db.Error()
+	}
+	// ignore to write header
+	if db.isAdd {
+		if err := db.assignAutoInc(); err != nil {
+			return err
+		}
+		if err := db.assignRecNo(); err != nil {
+			return err
+		}
+		if !db.tailPending {
+			// the records overwrite the trailing data, Flush writes it back
+			tail, err := db.trailing()
+			if err != nil {
+				return err
+			}
+			db.tail, db.tailPending = tail, true
+		}
+		if err := db.seekRecord(db.recCount() + 1); err != nil {
+			return err
+		}
+		if err := db.fileWrite(db.buffer); err != nil {
+			return err
+		}
+		db.recordNum++
+		db.header.RecCount++
+		db.isAdd = false
+	} else {
+		if db.recordNum == 0 {
+			return nil
+		}
+		//edit
+		if err := db.seekRecord(db.recordNum); err != nil {
+			return err
+		}
+		if err := db.fileWrite(db.buffer); err != nil {
+			return err
+		}
+	}
+	db.isMod = true
+	return nil
+}
+
+// assignAutoInc sets the blank autoincrement fields of the new record to their
+// next value.
+func (db *XBase) assignAutoInc() error {
+	for _, f := range db.fields {
+		if f.Type != FieldType_Autoincrement {
+			continue
+		}
+		b, err := f.buffer(db.buffer)
+		if err != nil {
+			return err
+		}
+		if !isBlank(b) {
+			continue
+		}
+		next := f.autoIncNext()
+		if err := f.setAutoIncValue(db.buffer, next); err != nil {
+			return err
+		}
+		f.setAutoIncNext(next + 1)
+		db.fieldsMod = true
+	}
+	return nil
+}
+
+// SetRecNoField sets the numeric ("N") field which Save fills with the
+// 1-based record number of every appended record. An empty name turns it off.
+func (db *XBase) SetRecNoField(name string) error {
+	if name == "" {
+		db.recNoField = ""
+		return nil
+	}
+	f, err := db.fieldByName("SetRecNoField", name)
+	if err != nil {
+		return err
+	}
+	if f.Type != FieldType_Numeric || f.Dec != 0 {
+		return fmt.Errorf("xbase: SetRecNoField: field %q is not an integer numeric field", f.name())
+	}
+	db.recNoField = f.name()
+	return nil
+}
+
+// assignRecNo sets the record number field of the new record.
+func (db *XBase) assignRecNo() error {
+	if db.recNoField == "" {
+		return nil
+	}
+	fieldNo := db.FieldNo(db.recNoField)
+	if fieldNo == 0 {
+		return fmt.Errorf("xbase: record number field %q not found", db.recNoField)
+	}
+	return db.fields[fieldNo-1].setIntValue(db.buffer, db.recCount()+1)
+}
+
+// AutoIncNext returns the next value of the autoincrement ("+") field.
+func (db *XBase) AutoIncNext(fieldName string) (int64, error) {
+	f, err := db.autoIncField(fieldName)
+	if err != nil {
+		return 0, err
+	}
+	return f.autoIncNext(), nil
+}
+
+// SetAutoIncNext sets the next value of the autoincrement ("+") field,
+// e.g. to resync the counter after an out-of-band import.
+// The field descriptor is written by Flush.
+func (db *XBase) SetAutoIncNext(fieldName string, v int64) error {
+	f, err := db.autoIncField(fieldName)
+	if err != nil {
+		return err
+	}
+	f.setAutoIncNext(v)
+	db.fieldsMod = true
+	db.isMod = true
+	return nil
+}
+
+func (db *XBase) autoIncField(fieldName string) (*field, error) {
+	no := db.FieldNo(fieldName)
+	if no == 0 {
+		return nil, fmt.Errorf("xbase: field %q not found", fieldName)
+	}
+	f := db.fields[no-1]
+	if err := f.checkType(FieldType_Autoincrement); err != nil {
+		return nil, fmt.Errorf("xbase: field %q: %w", fieldName, err)
+	}
+	return f, nil
 }
 
 // Del marks the current record as "deleted".
 // The record is not physically deleted from the file
 // and can be subsequently restored.
 func (db *XBase) Del() {
+	defer db.lock()()
 	db.buffer[0] = '*'
 }
 
 // RecDeleted returns the value of the delete flag for the current record.
 func (db *XBase) RecDeleted() bool {
+	defer db.rlock()()
 	return db.buffer[0] == '*'
 }
 
 // Recall removes the deletion mark from the current record.
 func (db *XBase) Recall() {
+	defer db.lock()()
 	db.buffer[0] = ' '
 }
 
+// SetDeleted sets the deletion flag of the record recNo without loading it
+// or moving the cursor. The flag is written to the file right away.
+// Records are numbered starting from 1.
+func (db *XBase) SetDeleted(recNo int64, deleted bool) error {
+	if recNo < 1 || recNo > db.recCount() {
+		return fmt.Errorf("xbase: SetDeleted: record number %d out of range", recNo)
+	}
+	flag := byte(' ')
+	if deleted {
+		flag = '*'
+	}
+	if err := db.seekRecord(recNo); err != nil {
+		return err
+	}
+	if err := db.fileWrite([]byte{flag}); err != nil {
+		return err
+	}
+	if recNo == db.recordNum && !db.isAdd {
+		db.buffer[0] = flag
+	}
+	db.isMod = true
+	return nil
+}
+
+// WriteRecordAt overwrites the record recNo with values, one per field in
+// field order. A nil value leaves the field blank. The record is built in a
+// separate buffer and written in place, the cursor isn't moved, but the
+// current record is updated if it's the one written.
+func (db *XBase) WriteRecordAt(recNo int64, values []interface{}) error {
+	defer db.lock()()
+	if db.Error() != nil {
+		return db.Error()
+	}
+	if recNo < 1 || recNo > db.recCount() {
+		return fmt.Errorf("xbase: WriteRecordAt: record number %d out of range", recNo)
+	}
+	if len(values) != len(db.fields) {
+		return fmt.Errorf("xbase: WriteRecordAt: got %d values for %d fields", len(values), len(db.fields))
+	}
+	buf := make([]byte, len(db.buffer))
+	for i := range buf {
+		buf[i] = ' '
+	}
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		if err := db.setValueIn(buf, i, value); err != nil {
+			return fmt.Errorf("xbase: WriteRecordAt: field %q: %w", db.fields[i].name(), err)
+		}
+	}
+	if err := db.seekRecord(recNo); err != nil {
+		return err
+	}
+	if err := db.fileWrite(buf); err != nil {
+		return err
+	}
+	if recNo == db.recordNum && !db.isAdd {
+		copy(db.buffer, buf)
+	}
+	db.isMod = true
+	return nil
+}
+
+// ReplaceOption configures ReplaceAll.
+type ReplaceOption func(*replaceOptions)
+
+type replaceOptions struct {
+	includeDeleted bool
+}
+
+// ReplaceIncludeDeleted makes ReplaceAll replace the values of the deleted
+// records too.
+func ReplaceIncludeDeleted() ReplaceOption {
+	return func(o *replaceOptions) {
+		o.includeDeleted = true
+	}
+}
+
+// ReplaceAll sets the field fieldNo to newValue in every not deleted record
+// where it equals oldValue and returns the number of records changed, like
+// REPLACE ALL FOR of dBase. Both values are formatted for the field type as
+// by SetFieldValue and compared as stored, so e.g. 12 matches "   12" in a
+// numeric field. A nil value is a blank one. Fields are numbered starting
+// from 1. It doesn't move the cursor, the current record is updated if it's
+// changed.
+func (db *XBase) ReplaceAll(fieldNo int, oldValue, newValue interface{}, opts ...ReplaceOption) (int64, error) {
+	var o replaceOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if db.readOnly {
+		return 0, ErrReadOnly
+	}
+	if fieldNo < 1 || fieldNo > len(db.fields) {
+		return 0, fmt.Errorf("xbase: ReplaceAll: field number %d out of range", fieldNo)
+	}
+	if db.isAdd {
+		return 0, fmt.Errorf("current record is add model,Save it first")
+	}
+	i := fieldNo - 1
+	f := db.fields[i]
+	oldBuf := bytes.Repeat([]byte{' '}, len(db.buffer))
+	if oldValue != nil {
+		if err := f.setValue(oldBuf, oldValue, db.valueOptions()); err != nil {
+			return 0, fmt.Errorf("xbase: ReplaceAll: field %q: %w", f.name(), err)
+		}
+	}
+	newBuf := bytes.Repeat([]byte{' '}, len(db.buffer))
+	if newValue != nil {
+		if err := db.setValueIn(newBuf, i, newValue); err != nil {
+			return 0, fmt.Errorf("xbase: ReplaceAll: field %q: %w", f.name(), err)
+		}
+	}
+	oldBytes, err := f.buffer(oldBuf)
+	if err != nil {
+		return 0, fmt.Errorf("xbase: ReplaceAll: field %q: %w", f.name(), err)
+	}
+	newBytes, err := f.buffer(newBuf)
+	if err != nil {
+		return 0, fmt.Errorf("xbase: ReplaceAll: field %q: %w", f.name(), err)
+	}
+
+	var recNos []int64
+	err = db.scan(func(recNo int64, buf []byte) error {
+		if recNo < db.firstRecNo() || !o.includeDeleted && buf[0] == '*' {
+			return nil
+		}
+		b, err := f.buffer(buf)
+		if err != nil {
+			return fmt.Errorf("xbase: ReplaceAll: record %d field %q: %w", recNo, f.name(), err)
+		}
+		if bytes.Equal(b, oldBytes) {
+			recNos = append(recNos, recNo)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for n, recNo := range recNos {
+		if err := db.seekRecord(recNo); err != nil {
+			return int64(n), err
+		}
+		if _, err := db.rws.Seek(int64(f.Offset), io.SeekCurrent); err != nil {
+			return int64(n), err
+		}
+		if err := db.fileWrite(newBytes); err != nil {
+			return int64(n), err
+		}
+		if recNo == db.recordNum {
+			if err := f.setBuffer(db.buffer, string(newBytes)); err != nil {
+				return int64(n), err
+			}
+		}
+		db.isMod = true
+	}
+	return int64(len(recNos)), db.Flush()
+}
+
+// Compact removes the records marked as deleted from the file. The header,
+// the field descriptors and the data after the file end marker are kept as
+// they are, so the structure, the code page and the modification date don't
+// change. The record number field set by SetRecNoField is renumbered.
+//
+// If the file is an *os.File, the live records are written to a temporary
+// file in tmpDir, or in the directory of the file if tmpDir is "", which
+// then replaces the file by a rename, so a crash leaves either the old or the
+// new file. tmpDir must be on the same file system. Other seekers are
+// rewritten in place and must support truncation, like SeekableBuffer.
+//
+// The cursor is reset, the next Next or Read starts from the first record.
+func (db *XBase) Compact(tmpDir string) error {
+	if db.readOnly {
+		return ErrReadOnly
+	}
+	if db.isAdd {
+		return fmt.Errorf("xbase: Compact: current record is in add mode, save it first")
+	}
+	if err := db.Flush(); err != nil {
+		return err
+	}
+	var (
+		data  bytes.Buffer
+		count int64
+	)
+	err := db.scan(func(recNo int64, buf []byte) error {
+		if recNo >= db.firstRecNo() && buf[0] == '*' {
+			return nil
+		}
+		count++
+		if recNo >= db.firstRecNo() && db.recNoField != "" {
+			if err := db.fields[db.FieldNo(db.recNoField)-1].setIntValue(buf, count); err != nil {
+				return err
+			}
+		}
+		data.Write(buf)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("xbase: Compact: %w", err)
+	}
+	tail, err := db.trailing()
+	if err != nil {
+		return fmt.Errorf("xbase: Compact: %w", err)
+	}
+	if !db.noEOFMarker || len(tail) > 0 {
+		data.WriteByte(fileEnd)
+		data.Write(tail)
+	}
+	head := make([]byte, db.base+int64(db.header.DataOffset))
+	if _, err = db.rws.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err = io.ReadFull(db.rws, head); err != nil {
+		return fmt.Errorf("xbase: Compact: %w", err)
+	}
+	binary.LittleEndian.PutUint32(head[db.base+4:], uint32(count))
+
+	if f, ok := db.seeker().(*os.File); ok {
+		err = db.replaceFile(f, tmpDir, head, data.Bytes())
+	} else {
+		err = db.rewrite(head, data.Bytes())
+	}
+	if err != nil {
+		return fmt.Errorf("xbase: Compact: %w", err)
+	}
+	db.header.RecCount = uint32(count)
+	db.recordNum = 0
+	db.clearBuf()
+	return nil
+}
+
+// replaceFile writes head and data to a temporary file in dir and renames
+// it over f, which is then reopened.
+func (db *XBase) replaceFile(f *os.File, dir string, head, data []byte) error {
+	name := f.Name()
+	if dir == "" {
+		dir = filepath.Dir(name)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(head); err == nil {
+		_, err = tmp.Write(data)
+	}
+	if err == nil {
+		err = tmp.Chmod(fi.Mode())
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	// the file must be closed to be replaced on Windows
+	if err = f.Close(); err != nil {
+		return err
+	}
+	renameErr := os.Rename(tmp.Name(), name)
+	nf, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	if err = db.setSeeker(nf); err != nil {
+		return err
+	}
+	return renameErr
+}
+
+// rewrite replaces the content of the seeker with head and data.
+func (db *XBase) rewrite(head, data []byte) error {
+	if !db.canTruncate() {
+		return errors.New("the seeker can't be truncated")
+	}
+	if _, err := db.rws.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := db.fileWrite(head); err != nil {
+		return err
+	}
+	if err := db.fileWrite(data); err != nil {
+		return err
+	}
+	return db.truncate(int64(len(head) + len(data)))
+}
+
+// DeletedCount returns the number of records marked as deleted in the file.
+// It reads only the deletion flags and doesn't move the cursor.
+func (db *XBase) DeletedCount() (int64, error) {
+	var n int64
+	for recNo := db.firstRecNo(); recNo <= db.recCount(); recNo++ {
+		deleted, err := db.deletedAt(recNo)
+		if err != nil {
+			return 0, err
+		}
+		if deleted {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// LiveCount returns the number of records not marked as deleted in the file.
+// It reads only the deletion flags and doesn't move the cursor.
+func (db *XBase) LiveCount() (int64, error) {
+	n, err := db.DeletedCount()
+	if err != nil {
+		return 0, err
+	}
+	return db.recCount() - db.firstRecNo() + 1 - n, nil
+}
+
 // Clear zeroes the field values ​​of the current record and error.
 func (db *XBase) Clear() {
+	defer db.lock()()
 	db.clearBuf()
-	db.err = nil
+	db.setErr(nil)
 	db.isAdd = false
 }
 
+// Validate checks the structural integrity of the DBF file and returns the
+// problems found. It doesn't modify the file.
+func (db *XBase) Validate() []error {
+	var errs []error
+	h := db.header
+	want := h.size() + len(db.fields)*h.fieldSize() + 1
+	if int(h.DataOffset) != want && !(h.level7() && int(h.DataOffset) > want) {
+		errs = append(errs, fmt.Errorf("xbase: data offset %d, want %d for %d fields", h.DataOffset, want, len(db.fields)))
+	}
+	if size := db.calcRecSize(); h.RecSize != size {
+		errs = append(errs, fmt.Errorf("xbase: record size %d, want %d from field lengths", h.RecSize, size))
+	}
+	for i, f := range db.fields {
+		if bytes.IndexByte([]byte(knownFieldTypes), f.Type) < 0 {
+			errs = append(errs, fmt.Errorf("xbase: field %d %q: unknown type %q", i+1, f.name(), string(f.Type)))
+		}
+	}
+	if db.rws == nil {
+		return errs
+	}
+	pos, err := db.rws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return append(errs, err)
+	}
+	defer db.rws.Seek(pos, io.SeekStart)
+	if _, err = db.rws.Seek(db.base+int64(h.DataOffset)-1, io.SeekStart); err != nil {
+		return append(errs, err)
+	}
+	b := make([]byte, 1)
+	if _, err = io.ReadFull(db.rws, b); err != nil || b[0] != headerEnd {
+		errs = append(errs, fmt.Errorf("xbase: missing header terminator at offset %d", h.DataOffset-1))
+	}
+	size, err := db.rws.Seek(0, io.SeekEnd)
+	if err != nil {
+		return append(errs, err)
+	}
+	dataSize := db.dataEnd()
+	if size != dataSize && size != dataSize+1 {
+		errs = append(errs, fmt.Errorf("xbase: file size %d doesn't match %d records, want %d", size, db.recCount(), dataSize+1))
+	}
+	return errs
+}
+
+// FillRatios returns for every field the fraction of not deleted records
+// whose value is not blank. A header row, see SetFirstRecordIsHeader, is
+// skipped.
+func (db *XBase) FillRatios() (map[string]float64, error) {
+	filled := make([]int64, len(db.fields))
+	var live int64
+	err := db.scanFrom(db.firstRecNo(), func(_ int64, buf []byte) error {
+		if buf[0] == '*' {
+			return nil
+		}
+		live++
+		for i, f := range db.fields {
+			b, err := f.buffer(buf)
+			if err != nil {
+				return err
+			}
+			if !isBlank(b) {
+				filled[i]++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	ratios := make(map[string]float64, len(db.fields))
+	for i, f := range db.fields {
+		if live > 0 {
+			ratios[f.name()] = float64(filled[i]) / float64(live)
+		} else {
+			ratios[f.name()] = 0
+		}
+	}
+	return ratios, nil
+}
+
+// Truncate keeps only the first n records and cuts the rest from the file.
+// It does nothing if n >= RecCount. The underlying seeker must support
+// truncation, like *os.File.
+func (db *XBase) Truncate(n int64) error {
+	if n < 0 {
+		return fmt.Errorf("xbase: Truncate: negative record count %d", n)
+	}
+	if n >= db.recCount() {
+		return nil
+	}
+	if !db.canTruncate() {
+		return fmt.Errorf("xbase: Truncate: %T doesn't support truncation", db.seeker())
+	}
+	tail, err := db.trailing()
+	if err != nil {
+		return err
+	}
+	if err := db.truncate(db.base + int64(db.header.DataOffset) + n*int64(db.header.RecSize)); err != nil {
+		return err
+	}
+	db.header.RecCount = uint32(n)
+	db.isMod = true
+	if err := db.writeTrailing(tail); err != nil {
+		return err
+	}
+	if err := db.Flush(); err != nil {
+		return err
+	}
+	if db.recordNum > n {
+		db.recordNum = n
+		if n == 0 {
+			db.clearBuf()
+			return nil
+		}
+		return db.GoTo(n)
+	}
+	return nil
+}
+
 // RecCount returns the number of records in the DBF file.
 func (db *XBase) RecCount() int64 {
 	return db.recCount()
@@ -454,25 +2192,193 @@ func (db *XBase) FieldCount() int {
 	return len(db.fields)
 }
 
+// RecSize returns the record size stored in the header: the deletion flag
+// byte followed by the fields, plus any filler bytes.
+func (db *XBase) RecSize() int {
+	return int(db.header.RecSize)
+}
+
+// DataOffset returns the header length stored in the header: the offset of
+// the first record from the start of the header, past the field
+// descriptors and the header terminator.
+func (db *XBase) DataOffset() int {
+	return int(db.header.DataOffset)
+}
+
+// FieldLength returns the declared length of the field.
+// Fields are numbered starting from 1.
+func (db *XBase) FieldLength(fieldNo int) (n int) {
+	if db.Error() != nil {
+		return
+	}
+	defer db.wrapFieldError("FieldLength", fieldNo)
+	return int(db.fieldByNo(fieldNo).Len)
+}
+
+// FieldDecimals returns the number of decimal places of the field.
+// Fields are numbered starting from 1.
+func (db *XBase) FieldDecimals(fieldNo int) (n int) {
+	if db.Error() != nil {
+		return
+	}
+	defer db.wrapFieldError("FieldDecimals", fieldNo)
+	return int(db.fieldByNo(fieldNo).Dec)
+}
+
+// FieldMaxInt returns the largest integer that fits the numeric or float
+// field. Fields are numbered starting from 1.
+func (db *XBase) FieldMaxInt(fieldNo int) (n int64) {
+	if db.Error() != nil {
+		return
+	}
+	defer db.wrapFieldError("FieldMaxInt", fieldNo)
+	f := db.fieldByNo(fieldNo)
+	if err := f.checkNumeric(); err != nil {
+		panic(err)
+	}
+	return f.MaxInt()
+}
+
+// FieldMinInt returns the smallest integer that fits the numeric or float
+// field, the minus sign takes one position. Fields are numbered starting
+// from 1.
+func (db *XBase) FieldMinInt(fieldNo int) (n int64) {
+	if db.Error() != nil {
+		return
+	}
+	defer db.wrapFieldError("FieldMinInt", fieldNo)
+	f := db.fieldByNo(fieldNo)
+	if err := f.checkNumeric(); err != nil {
+		panic(err)
+	}
+	return f.MinInt()
+}
+
+// FieldType returns the type of the field, e.g. 'N'.
+// Fields are numbered starting from 1.
+func (db *XBase) FieldType(fieldNo int) (typ byte) {
+	if db.Error() != nil {
+		return
+	}
+	defer db.wrapFieldError("FieldType", fieldNo)
+	return db.fieldByNo(fieldNo).Type
+}
+
+// RenameField renames the field oldName to newName. Only the field
+// descriptor is rewritten, by Flush. The file must be open read-write.
+func (db *XBase) RenameField(oldName, newName string) error {
+	if db.readOnly {
+		return ErrReadOnly
+	}
+	fieldNo := db.FieldNo(oldName)
+	if fieldNo == 0 {
+		return fmt.Errorf("xbase: RenameField: field %q not found", oldName)
+	}
+	if n := db.FieldNo(newName); n != 0 && n != fieldNo {
+		return fmt.Errorf("xbase: RenameField: field %q already exists", newName)
+	}
+	f := *db.fields[fieldNo-1]
+	if err := db.setFieldName(&f, newName); err != nil {
+		return fmt.Errorf("xbase: RenameField: %w", err)
+	}
+	*db.fields[fieldNo-1] = f
+	db.fieldsMod = true
+	db.isMod = true
+	return nil
+}
+
+// FileType returns the type of the DBF file, FileTypeDBase3 or
+// FileTypeDBase7. It's the first byte of the header, the version of the
+// file format.
+func (db *XBase) FileType() byte {
+	return db.header.DbfId
+}
+
+// SetFileType sets the type of a new DBF file, it must be called before
+// CreateFile. FileTypeDBase7 allows field names of up to 32 characters,
+// FileTypeDBase3 (the default) up to 10.
+func (db *XBase) SetFileType(typ byte) error {
+	if db.rws != nil {
+		return fmt.Errorf("xbase: SetFileType: file is already created")
+	}
+	switch typ {
+	case FileTypeDBase3:
+		for _, f := range db.fields {
+			if len(f.name()) > maxFieldNameLen {
+				return fmt.Errorf("xbase: SetFileType: too long field name: %q, max len %d", f.name(), maxFieldNameLen)
+			}
+		}
+	case FileTypeDBase7:
+	default:
+		return fmt.Errorf("xbase: SetFileType: unsupported file type 0x%02x", typ)
+	}
+	db.header.DbfId = typ
+	return nil
+}
+
+// maxFieldNameLen returns the maximum length of field names for the file type.
+func (db *XBase) maxFieldNameLen() int {
+	if db.header.level7() {
+		return maxLongFieldNameLen
+	}
+	return maxFieldNameLen
+}
+
 // FieldNo returns the number of the field by name.
 // If name is not found returns 0.
 // Fields are numbered starting from 1.
+// Names are compared case-insensitively.
 func (db *XBase) FieldNo(name string) int {
-	name = strings.ToUpper(strings.TrimSpace(name))
+	name = strings.TrimSpace(name)
 	for i, f := range db.fields {
-		if f.name() == name {
+		if strings.EqualFold(f.name(), name) {
 			return i + 1
 		}
 	}
-	return 0
+	return 0
+}
+
+// SetPreserveFieldCase sets whether AddField, AddFieldMigrate and RenameField
+// store field names as given instead of upper-casing them, e.g. for tools
+// matching names case-sensitively. Lookups by name stay case-insensitive.
+func (db *XBase) SetPreserveFieldCase(b bool) {
+	db.preserveCase = b
+}
+
+// newField returns a new field with the name length and case rules of db.
+func (db *XBase) newField(name string, typ string, length, dec int) (*field, error) {
+	f, err := newField(name, typ, length, dec, db.maxFieldNameLen())
+	if err != nil {
+		return nil, err
+	}
+	if db.preserveCase {
+		if err = f.setNameCase(name, db.maxFieldNameLen()); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// setFieldName sets the name of f with the name length and case rules of db.
+func (db *XBase) setFieldName(f *field, name string) error {
+	if db.preserveCase {
+		return f.setNameCase(name, db.maxFieldNameLen())
+	}
+	return f.setNameLen(name, db.maxFieldNameLen())
 }
 
 // AddField adds a field to the structure of the DBF file.
-// This method can only be used before creating a new file.
+// This method can only be used before creating a new file,
+// use AddFieldMigrate to add a field to an existing file.
 //
-// The following field types are supported: "C", "N", "F", "L", "D".
+// The following field types are supported: "C", "N", "F", "L", "D", "+", "B".
+// "B" is the 8 byte double of dBase IV, not a memo block number.
+// Types can also be given by name as accepted by ParseFieldType, e.g. "numeric".
 //
 // The opts parameter contains optional parameters: field length and number of decimal places.
+// If the length is omitted or 0, the field gets the default length of its type:
+// 254 for "C", 10 for "N" and 12 with 2 decimals for "F". The other types have
+// a fixed length.
 //
 // Examples:
 //     db.AddField("NAME", "C", 24)
@@ -480,6 +2386,8 @@ func (db *XBase) FieldNo(name string) int {
 //     db.AddField("PRICE", "F", 12, 2)
 //     db.AddField("FLAG", "L")
 //     db.AddField("DATE", "D")
+//     db.AddField("ID", "+")
+//     db.AddField("RATIO", "B")
 func (db *XBase) AddField(name string, typ string, opts ...int) error {
 	length := 0
 	dec := 0
@@ -489,7 +2397,7 @@ func (db *XBase) AddField(name string, typ string, opts ...int) error {
 	if len(opts) > 1 {
 		dec = opts[1]
 	}
-	f, err := NewField(name, typ, length, dec)
+	f, err := db.newField(name, typ, length, dec)
 	if err != nil {
 		return err
 	}
@@ -497,6 +2405,201 @@ func (db *XBase) AddField(name string, typ string, opts ...int) error {
 	return nil
 }
 
+// AddFieldMigrate adds a field to the structure of an existing DBF file.
+// Unlike AddField, it can be used on a populated file: the whole file is
+// rewritten with the new field appended to every record as a blank value.
+// The file must be open read-write. The opts parameter is the same as for
+// AddField.
+func (db *XBase) AddFieldMigrate(name string, typ string, opts ...int) error {
+	if db.readOnly {
+		return ErrReadOnly
+	}
+	if db.rws == nil {
+		return fmt.Errorf("xbase: AddFieldMigrate: file is not created, use AddField")
+	}
+	if db.FieldNo(name) != 0 {
+		return fmt.Errorf("xbase: AddFieldMigrate: field %q already exists", strings.ToUpper(strings.TrimSpace(name)))
+	}
+	length, dec := 0, 0
+	if len(opts) > 0 {
+		length = opts[0]
+	}
+	if len(opts) > 1 {
+		dec = opts[1]
+	}
+	nf, err := db.newField(name, typ, length, dec)
+	if err != nil {
+		return fmt.Errorf("xbase: AddFieldMigrate: %w", err)
+	}
+	from := make([]*field, len(db.fields), len(db.fields)+1)
+	copy(from, db.fields)
+	return db.migrate(append(db.fields, nf), append(from, nil))
+}
+
+// DropField removes a field from the structure of an existing DBF file.
+// The whole file is rewritten without the values of the field. The file
+// must be open read-write and its seeker must support truncation,
+// like *os.File.
+func (db *XBase) DropField(name string) error {
+	if db.readOnly {
+		return ErrReadOnly
+	}
+	f, err := db.fieldByName("DropField", name)
+	if err != nil {
+		return err
+	}
+	if len(db.fields) == 1 {
+		return fmt.Errorf("xbase: DropField: can't drop the only field %q", f.name())
+	}
+	if !db.canTruncate() {
+		return fmt.Errorf("xbase: DropField: %T doesn't support truncation", db.seeker())
+	}
+	fields := make([]*field, 0, len(db.fields)-1)
+	transforms := make(map[int]func(interface{}) (interface{}, error))
+	for i, g := range db.fields {
+		if g == f {
+			continue
+		}
+		if fn := db.transforms[i]; fn != nil {
+			transforms[len(fields)] = fn
+		}
+		fields = append(fields, g)
+	}
+	if err = db.migrate(fields, fields); err != nil {
+		return err
+	}
+	db.transforms = transforms
+	if db.recNoField == f.name() {
+		db.recNoField = ""
+	}
+	return nil
+}
+
+// migrate rewrites the file with the new fields. The value of fields[i] is
+// copied from from[i] as laid out before, or blank if from[i] is nil.
+func (db *XBase) migrate(fields, from []*field) (err error) {
+	if err = db.Flush(); err != nil {
+		return err
+	}
+	var records [][]byte
+	err = db.scan(func(recNo int64, buf []byte) error {
+		// check the records before the file is rewritten
+		for _, f := range db.fields {
+			if err := f.checkBuffer(buf); err != nil {
+				return fmt.Errorf("xbase: record %d field %q: %w", recNo, f.name(), err)
+			}
+		}
+		records = append(records, append([]byte(nil), buf...))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	tail, err := db.trailing()
+	if err != nil {
+		return err
+	}
+	oldFields := make([]*field, len(from))
+	for i, f := range from {
+		if f != nil {
+			old := *f
+			oldFields[i] = &old
+		}
+	}
+
+	db.fields = fields
+	db.header.setFieldCount(len(db.fields))
+	db.header.RecSize = db.calcRecSize()
+	if err = db.writeHeader(); err != nil {
+		return err
+	}
+	if err = db.writeFields(); err != nil {
+		return err
+	}
+	buf := make([]byte, int(db.header.RecSize))
+	for _, rec := range records {
+		for i := range buf {
+			buf[i] = ' '
+		}
+		buf[0] = rec[0]
+		for i, f := range db.fields {
+			switch {
+			case oldFields[i] != nil:
+				b, err := oldFields[i].buffer(rec)
+				if err != nil {
+					return err
+				}
+				if err = f.setBuffer(buf, string(b)); err != nil {
+					return err
+				}
+			case f.Type == FieldType_Autoincrement || f.Type == FieldType_Binary:
+				if err = f.setBuffer(buf, string(make([]byte, f.Len))); err != nil {
+					return err
+				}
+			}
+		}
+		if err = db.fileWrite(buf); err != nil {
+			return err
+		}
+	}
+	if err = db.fileWrite(append([]byte{fileEnd}, tail...)); err != nil {
+		return err
+	}
+	if db.canTruncate() {
+		if err = db.truncate(db.dataEnd() + 1 + int64(len(tail))); err != nil {
+			return err
+		}
+	}
+	db.makeBuf()
+	db.isMod = true
+	// the encoder and the decoder cache the old structure
+	db.marshal = nil
+	db.unmarshal = nil
+	if db.recordNum > 0 {
+		return db.GoTo(db.recordNum)
+	}
+	return nil
+}
+
+// SetRecordAlignment pads every record with filler bytes after the fields,
+// so that the record size is a multiple of n.
+// The alignment is applied when the record size is computed: by CreateFile
+// for a new file and by AddFieldMigrate and DropField, which rewrite the
+// records of an existing file. It doesn't change the record size otherwise.
+func (db *XBase) SetRecordAlignment(n int) {
+	db.recAlign = n
+}
+
+// SetNumericPadding sets how numeric values are padded when written.
+// The default is PaddingSpace, PaddingZero writes e.g. "00123" and "-0123".
+// Reading numeric values accepts both paddings.
+func (db *XBase) SetNumericPadding(p NumericPadding) {
+	db.numPadding = p
+}
+
+// SetLenientSet sets whether SetFieldValue and Write convert values of
+// compatible types to the field type, e.g. the string "12.5" to a numeric
+// field, an int to a float field or a float to a character field. By default
+// the value type must match the field type: integers for numeric ("N")
+// fields and floats for float ("F") fields.
+func (db *XBase) SetLenientSet(b bool) {
+	db.lenient = b
+}
+
+// SetLogicalFormat sets the bytes written for true and false to logical
+// fields. The default is 'T' and 'F'. trueByte must be one of T, t, Y, y
+// and falseByte one of F, f, N, n, which are read back as true and false.
+func (db *XBase) SetLogicalFormat(trueByte, falseByte byte) error {
+	if bytes.IndexByte([]byte("TtYy"), trueByte) < 0 {
+		return fmt.Errorf("xbase: invalid logical true byte %q, want T, t, Y or y", trueByte)
+	}
+	if bytes.IndexByte([]byte("FfNn"), falseByte) < 0 {
+		return fmt.Errorf("xbase: invalid logical false byte %q, want F, f, N or n", falseByte)
+	}
+	db.logical = [2]byte{trueByte, falseByte}
+	return nil
+}
+
 // SetCodePage sets the encoding mode for reading and writing string field values.
 // The default code page is 0.
 //
@@ -525,6 +2628,160 @@ func (db *XBase) SetCodePage(cp int) {
 	db.header.setCodePage(cp)
 }
 
+// SetOnUnmappable sets how Recode handles characters that can't be
+// represented in the target code page. The default is UnmappableError.
+func (db *XBase) SetOnUnmappable(u OnUnmappable) {
+	db.onUnmappable = u
+}
+
+// Recode transcodes the character fields of all records from the current
+// code page to newCP, rewrites the records and updates the code page of the
+// DBF file. All records are transcoded and checked before any of them is
+// written, so on error the file is left unchanged. The cursor keeps its
+// position.
+func (db *XBase) Recode(newCP int) error {
+	cm := charMapByPage(newCP)
+	if cm == nil {
+		return fmt.Errorf("xbase: unsupported code page %d", newCP)
+	}
+	if db.readOnly {
+		return ErrReadOnly
+	}
+	if db.isAdd {
+		return fmt.Errorf("current record is add model,Save it first")
+	}
+	tmp := make([]byte, len(db.buffer))
+	err := db.scan(func(recNo int64, buf []byte) error {
+		copy(tmp, buf)
+		_, err := db.recodeRecord(tmp, recNo, cm, newCP)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for recNo := int64(1); recNo <= db.recCount(); recNo++ {
+		if err := db.readRecordAt(recNo, tmp); err != nil {
+			return err
+		}
+		changed, err := db.recodeRecord(tmp, recNo, cm, newCP)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			continue
+		}
+		if err := db.seekRecord(recNo); err != nil {
+			return err
+		}
+		if err := db.fileWrite(tmp); err != nil {
+			return err
+		}
+		if recNo == db.recordNum {
+			copy(db.buffer, tmp)
+		}
+	}
+	db.SetCodePage(newCP)
+	db.isMod = true
+	return db.Flush()
+}
+
+// recodeRecord transcodes the character fields of the record recNo in buf
+// from the current code page to cm in place and reports whether a value
+// changed.
+func (db *XBase) recodeRecord(buf []byte, recNo int64, cm *charmap.Charmap, newCP int) (changed bool, err error) {
+	for _, f := range db.fields {
+		if f.Type != FieldType_Character {
+			continue
+		}
+		s, err := f.stringValue(buf, db.decoder)
+		if err != nil {
+			return false, err
+		}
+		if isASCII(s) {
+			continue
+		}
+		b := make([]byte, 0, len(s))
+		for _, r := range s {
+			c, ok := cm.EncodeRune(r)
+			if !ok {
+				if db.onUnmappable != UnmappableReplace {
+					return false, fmt.Errorf("xbase: Recode: record %d field %q: character %q not in code page %d", recNo, f.name(), r, newCP)
+				}
+				c = '?'
+			}
+			b = append(b, c)
+		}
+		if err = f.checkLen(string(b)); err != nil {
+			return false, err
+		}
+		if err = f.setBuffer(buf, padRight(string(b), int(f.Len))); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// ProductionIndexName returns the file name of the production index (.mdx)
+// if the table flags mark that the table has one, otherwise "".
+// The name is derived from the name of the opened DBF file.
+func (db *XBase) ProductionIndexName() string {
+	if !db.header.hasProductionIndex() {
+		return ""
+	}
+	f, ok := db.seeker().(*os.File)
+	if !ok {
+		return ""
+	}
+	name := f.Name()
+	ext := filepath.Ext(name)
+	idx := productionIndexExt
+	if ext != "" && ext == strings.ToUpper(ext) {
+		idx = strings.ToUpper(idx)
+	}
+	return strings.TrimSuffix(name, ext) + idx
+}
+
+// HasMDX reports whether the table flags mark that the table has a
+// production index file. The index isn't maintained by XBase, so opening
+// such a table read-write reports ErrIndexNotMaintained by Warnings.
+func (db *XBase) HasMDX() bool {
+	return db.header.hasProductionIndex()
+}
+
+// SetHasMDX sets the table flag that the table has a production index file.
+// The header is written by Flush.
+func (db *XBase) SetHasMDX(b bool) {
+	db.header.setFlag(flagProductionIndex, b)
+	db.isMod = true
+}
+
+// HasMemo reports whether the table flags mark that the table has memo
+// fields. Memo fields aren't supported, so the flag is kept as read from the
+// file and only changed by SetHasMemo.
+func (db *XBase) HasMemo() bool {
+	return db.header.flag(flagMemo)
+}
+
+// SetHasMemo sets the table flag that the table has memo fields. XBase
+// never sets the flag itself.
+// The header is written by Flush.
+func (db *XBase) SetHasMemo(b bool) {
+	db.header.setFlag(flagMemo, b)
+	db.isMod = true
+}
+
+// HasProductionIndexFile reports whether the production index file named
+// by ProductionIndexName exists.
+func (db *XBase) HasProductionIndexFile() bool {
+	name := db.ProductionIndexName()
+	if name == "" {
+		return false
+	}
+	_, err := os.Stat(name)
+	return err == nil
+}
+
 // CodePage returns the code page of a DBF file.
 // Returns 0 if no code page is specified.
 func (db *XBase) CodePage() int {
@@ -536,39 +2793,156 @@ func (db *XBase) ModDate() time.Time {
 	return db.header.modDate()
 }
 
+// CreatedAt returns the creation time of the DBF file if it is stored in the
+// reserved header bytes, otherwise it falls back to ModDate.
+func (db *XBase) CreatedAt() time.Time {
+	return db.header.createdAt()
+}
+
+// ModifiedAt returns the modification time of the DBF file if it is stored in
+// the reserved header bytes, otherwise it falls back to ModDate.
+func (db *XBase) ModifiedAt() time.Time {
+	return db.header.modifiedAt()
+}
+
 // Error returns an error when working with a DBF file.
 func (db *XBase) Error() error {
+	if db.errMu != nil {
+		db.errMu.Lock()
+		defer db.errMu.Unlock()
+	}
 	return db.err
 }
 
+// setErr sets the error returned by Error. The field readers hold only the
+// read lock, so with WithThreadSafe err is guarded by its own mutex.
+func (db *XBase) setErr(err error) {
+	if db.errMu != nil {
+		db.errMu.Lock()
+		defer db.errMu.Unlock()
+	}
+	db.err = err
+}
+
 // writeFileEnd called when close file,should be written dbf file end tag
 func (db *XBase) writeFileEnd() (err error) {
-	size := int64(db.header.DataOffset) + db.RecCount()*int64(db.header.RecSize) + 1
-	if file, ok := db.rws.(fs.File); ok {
-		// check file size
-		fi, err := file.Stat()
-		if err != nil {
-			return err
-		}
-		if fi.Size()+1 != size {
-			// file has changed by outer,do nothing,believe outer
-			return nil
-		}
+	dataEnd := db.dataEnd()
+	size, err := db.rws.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if db.noEOFMarker {
+		return db.removeFileEnd(size)
 	}
+	if size != dataEnd {
+		// the end marker exists or the file has changed by outer,
+		// do nothing to keep trailing data
+		return nil
+	}
+	if _, err = db.rws.Seek(dataEnd, io.SeekStart); err != nil {
+		return err
+	}
+	return db.fileWrite([]byte{fileEnd})
+}
 
-	if _, err = db.rws.Seek(0, 2); err != nil {
-		// end file position
+// removeFileEnd cuts the file end marker if it is the last byte of the file
+// and the seeker supports truncation.
+func (db *XBase) removeFileEnd(size int64) error {
+	if !db.canTruncate() || size != db.dataEnd()+1 {
+		return nil
+	}
+	if _, err := db.rws.Seek(-1, io.SeekEnd); err != nil {
 		return err
 	}
-	if err = db.fileWrite([]byte{fileEnd}); err != nil {
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(db.rws, b); err != nil {
 		return err
 	}
-	return nil
+	if b[0] != fileEnd {
+		return nil
+	}
+	return db.truncate(size - 1)
+}
+
+// SetWriteEOFMarker sets whether Flush and Close write the file end marker
+// (0x1A) after the last record. It is on by default. When off, a marker
+// left at the end of the file is removed if the seeker supports truncation,
+// like *os.File. Trailing data after the marker is kept with it.
+func (db *XBase) SetWriteEOFMarker(b bool) {
+	db.noEOFMarker = !b
+}
+
+// TrailingBytes returns the number of bytes after the file end marker,
+// e.g. index or junk data appended to the file. Flush keeps them.
+func (db *XBase) TrailingBytes() (int64, error) {
+	if db.tailPending {
+		return int64(len(db.tail)), nil
+	}
+	size, err := db.rws.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	n := size - db.dataEnd() - 1
+	if n < 0 {
+		return 0, nil
+	}
+	return n, nil
+}
+
+// SetPreserveTrailing sets whether the data after the file end marker, e.g.
+// index blocks, is kept when records are appended or the file is truncated.
+// It is on by default.
+func (db *XBase) SetPreserveTrailing(b bool) {
+	db.dropTrailing = !b
+}
+
+// trailing returns the data after the file end marker to be preserved.
+func (db *XBase) trailing() ([]byte, error) {
+	if db.dropTrailing {
+		return nil, nil
+	}
+	if db.tailPending {
+		return db.tail, nil
+	}
+	n, err := db.TrailingBytes()
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	if _, err = db.rws.Seek(db.dataEnd()+1, io.SeekStart); err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	if _, err = io.ReadFull(db.rws, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// writeTrailing writes the file end marker and b after the last record.
+func (db *XBase) writeTrailing(b []byte) error {
+	db.tail, db.tailPending = nil, false
+	if len(b) == 0 || db.dropTrailing {
+		return nil
+	}
+	if _, err := db.rws.Seek(db.dataEnd(), io.SeekStart); err != nil {
+		return err
+	}
+	return db.fileWrite(append([]byte{fileEnd}, b...))
+}
+
+// dataEnd returns the offset of the end of the last record.
+func (db *XBase) dataEnd() int64 {
+	return db.base + int64(db.header.DataOffset) + db.recCount()*int64(db.header.RecSize)
 }
 
 // GoTo allows you to go to a record by its ordinal number.
 // Numbering starts from 1.
-func (db *XBase) GoTo(recNo int64) (err error) {
+func (db *XBase) GoTo(recNo int64) error {
+	defer db.lock()()
+	return db.goTo(recNo)
+}
+
+func (db *XBase) goTo(recNo int64) error {
 	if recNo < 1 {
 		return BOF
 	}
@@ -587,6 +2961,108 @@ func (db *XBase) GoTo(recNo int64) (err error) {
 	return nil
 }
 
+// lock locks mu for writing if set and returns the func unlocking it.
+func (db *XBase) lock() func() {
+	if db.mu == nil {
+		return func() {}
+	}
+	db.mu.Lock()
+	return db.mu.Unlock
+}
+
+// rlock is like lock but locks mu for reading.
+func (db *XBase) rlock() func() {
+	if db.mu == nil {
+		return func() {}
+	}
+	db.mu.RLock()
+	return db.mu.RUnlock
+}
+
+// scan calls fn with the number and the raw buffer of every record, without
+// moving the cursor. The buffer is reused between calls.
+func (db *XBase) scan(fn func(recNo int64, buf []byte) error) error {
+	return db.scanFrom(1, fn)
+}
+
+// scanFrom is like scan but starts at the record start.
+func (db *XBase) scanFrom(start int64, fn func(recNo int64, buf []byte) error) error {
+	if start < 1 || start > db.recCount() {
+		return nil
+	}
+	if err := db.seekRecord(start); err != nil {
+		return err
+	}
+	buf := make([]byte, len(db.buffer))
+	for _, f := range db.fields {
+		if err := f.checkBuffer(buf); err != nil {
+			return err
+		}
+	}
+	for recNo := start; recNo <= db.recCount(); recNo++ {
+		if _, err := io.ReadFull(db.rws, buf); err != nil {
+			return err
+		}
+		if err := fn(recNo, buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deletedAt reads the deletion flag of the record recNo without moving
+// the cursor.
+func (db *XBase) deletedAt(recNo int64) (bool, error) {
+	if err := db.seekRecord(recNo); err != nil {
+		return false, err
+	}
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(db.rws, b); err != nil {
+		return false, err
+	}
+	return b[0] == '*', nil
+}
+
+// readRecordAt reads the raw record recNo into buf without moving the cursor.
+func (db *XBase) readRecordAt(recNo int64, buf []byte) error {
+	if err := db.seekRecord(recNo); err != nil {
+		return err
+	}
+	_, err := io.ReadFull(db.rws, buf)
+	return err
+}
+
+// firstRecNo returns the number of the first data record.
+func (db *XBase) firstRecNo() int64 {
+	if db.headerRecord != nil {
+		return 2
+	}
+	return 1
+}
+
+// setValue applies the transform of the field with index i to value and
+// stores the result in the current record buffer.
+func (db *XBase) setValue(i int, value interface{}) error {
+	return db.setValueIn(db.buffer, i, value)
+}
+
+// setValueIn sets the value of the i-th field in the record buffer buf.
+func (db *XBase) setValueIn(buf []byte, i int, value interface{}) (err error) {
+	if err = db.fields[i].checkBuffer(buf); err != nil {
+		return
+	}
+	if fn := db.transforms[i]; fn != nil {
+		if value, err = fn(value); err != nil {
+			return
+		}
+	}
+	return db.fields[i].setValue(buf, value, db.valueOptions())
+}
+
+func (db *XBase) valueOptions() valueOptions {
+	return valueOptions{enc: db.encoder, padding: db.numPadding, lenient: db.lenient, logical: db.logical, truncate: db.truncPolicy}
+}
+
 func (db *XBase) makeBuf() {
 	db.buffer = make([]byte, int(db.header.RecSize))
 }
@@ -599,6 +3075,15 @@ func (db *XBase) fieldByNo(fieldNo int) *field {
 	return db.fields[fieldNo-1]
 }
 
+// fieldByName returns the field by name or an error naming the operation op.
+func (db *XBase) fieldByName(op, name string) (*field, error) {
+	fieldNo := db.FieldNo(name)
+	if fieldNo == 0 {
+		return nil, fmt.Errorf("xbase: %s: field %q not found", op, name)
+	}
+	return db.fields[fieldNo-1], nil
+}
+
 func (db *XBase) recCount() int64 {
 	return int64(db.header.RecCount)
 }
@@ -622,11 +3107,15 @@ func (db *XBase) checkRecNo() error {
 
 func (db *XBase) wrapFieldError(s string, fieldNo int) {
 	if r := recover(); r != nil {
+		err, ok := r.(error)
+		if !ok {
+			err = fmt.Errorf("%v", r)
+		}
 		prefix := fmt.Sprintf("xbase: %s: field %d", s, fieldNo)
 		if fieldNo < 1 || fieldNo > len(db.fields) {
-			db.err = fmt.Errorf("%s: %w", prefix, r)
+			db.setErr(fmt.Errorf("%s: %w", prefix, err))
 		} else {
-			db.err = fmt.Errorf("%s %q: %w", prefix, db.fields[fieldNo-1].name(), r)
+			db.setErr(fmt.Errorf("%s %q: %w", prefix, db.fields[fieldNo-1].name(), err))
 		}
 	}
 }
@@ -634,7 +3123,7 @@ func (db *XBase) wrapFieldError(s string, fieldNo int) {
 // seekRecord move the file ptr to the record start position
 // recordNo start 1
 func (db *XBase) seekRecord(recordNo int64) error {
-	offset := int64(db.header.DataOffset) + int64(db.header.RecSize)*(recordNo-1)
+	offset := db.base + int64(db.header.DataOffset) + int64(db.header.RecSize)*(recordNo-1)
 	_, err := db.rws.Seek(offset, 0)
 	return err
 }
@@ -644,6 +3133,9 @@ func (db *XBase) calcRecSize() uint16 {
 	for _, f := range db.fields {
 		size += int(f.Len)
 	}
+	if db.recAlign > 1 {
+		size = (size + db.recAlign - 1) / db.recAlign * db.recAlign
+	}
 	return uint16(size)
 }
 
@@ -654,10 +3146,16 @@ func (db *XBase) writeHeader() error {
 	if db.header.RecSize == 0 {
 		db.header.RecSize = db.calcRecSize()
 	}
-	if _, err := db.rws.Seek(0, 0); err != nil {
+	if _, err := db.rws.Seek(db.base, 0); err != nil {
 		return err
 	}
-	return db.header.write(db.rws)
+	if err := db.header.write(db.rws); err != nil {
+		return err
+	}
+	if db.header.level7() {
+		return db.fileWrite(db.header7[:])
+	}
+	return nil
 }
 
 // write the field description
@@ -665,7 +3163,11 @@ func (db *XBase) writeFields() error {
 	offset := 1 // deleted mark
 	for _, f := range db.fields {
 		f.Offset = uint32(offset)
-		if err := f.write(db.rws); err != nil {
+		write := f.write
+		if db.header.level7() {
+			write = f.write7
+		}
+		if err := write(db.rws); err != nil {
 			return err
 		}
 		offset += int(f.Len)
@@ -676,6 +3178,27 @@ func (db *XBase) writeFields() error {
 	return nil
 }
 
+// readFields7 reads dBase 7 field descriptors up to the header terminator.
+func (db *XBase) readFields7(reader io.Reader) error {
+	offset := 1 // deleted mark
+	b := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(reader, b); err != nil {
+			return err
+		}
+		if b[0] == headerEnd {
+			return nil
+		}
+		f := &field{}
+		if err := f.read7(io.MultiReader(bytes.NewReader(b), reader)); err != nil {
+			return err
+		}
+		f.Offset = uint32(offset)
+		db.fields = append(db.fields, f)
+		offset += int(f.Len)
+	}
+}
+
 func (db *XBase) readFields(reader io.Reader) error {
 	offset := 1 // deleted mark
 	count := db.header.fieldCount()
@@ -699,6 +3222,9 @@ func (db *XBase) clearBuf() {
 }
 
 func (db *XBase) fileWrite(b []byte) error {
+	if db.readOnly {
+		return ErrReadOnly
+	}
 	_, err := db.rws.Write(b)
 	return err
 }
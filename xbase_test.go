@@ -1,10 +1,21 @@
 package xbase
 
 import (
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"encoding/binary"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"io/ioutil"
+	"math/big"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -50,7 +61,7 @@ func addFields(db *XBase) {
 }
 
 func TestCreateEmptyFile(t *testing.T) {
-	db, _ := New(nil)
+	db := MustNew(nil)
 	addFields(db)
 	db.CreateFile("./testdata/test.dbf")
 
@@ -80,13 +91,13 @@ func TestSetFieldValueError(t *testing.T) {
 }
 
 func TestAddFieldError(t *testing.T) {
-	db, _ := New(nil)
+	db := MustNew(nil)
 	err := db.AddField("NAME", "X", 10)
 	require.Error(t, err)
 }
 
 func TestAddEmptyRec(t *testing.T) {
-	db, _ := New(nil)
+	db := MustNew(nil)
 	addFields(db)
 	db.CreateFile("./testdata/test.dbf")
 
@@ -107,7 +118,7 @@ func TestAddEmptyRec(t *testing.T) {
 }
 
 func TestAddRecords(t *testing.T) {
-	db, _ := New(nil)
+	db := MustNew(nil)
 	addFields(db)
 	db.CreateFile("./testdata/test.dbf")
 
@@ -292,7 +303,7 @@ func TestOpenAddRec(t *testing.T) {
 }
 
 func TestCreateEditRec(t *testing.T) {
-	db, _ := New(nil)
+	db := MustNew(nil)
 	db.AddField("NAME", "C", 3)
 	db.CreateFile("./testdata/test.dbf")
 
@@ -367,3 +378,2454 @@ func TestXBase_AddX(t *testing.T) {
 		})
 	}
 }
+
+func TestStreamEncoder(t *testing.T) {
+	buf := NewSeekableBuffer()
+	xb, err := New(buf)
+	require.NoError(t, err)
+	enc := xb.StreamEncoder()
+	for _, rec := range []*Rec{{Name: "a", Count: 1}, nil, {Name: "c", Count: 3}} {
+		require.NoError(t, enc.Push(rec))
+	}
+	require.NoError(t, enc.Finish())
+	assert.ErrorIs(t, enc.Push(&Rec{}), ErrStreamFinished)
+
+	rd, err := New(NewSeekableBufferWithBytes(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, int64(3), rd.RecCount())
+	require.NoError(t, rd.Last())
+	require.Equal(t, "c", rd.FieldValueAsString(1))
+	require.Equal(t, int64(3), rd.FieldValueAsInt(3))
+	require.Equal(t, fileEnd, buf.Bytes()[buf.Len()-1])
+}
+
+func TestSetRecordAlignment(t *testing.T) {
+	buf := NewSeekableBuffer()
+	xb, err := New(buf)
+	require.NoError(t, err)
+	xb.SetRecordAlignment(16)
+	d := time.Date(2021, 2, 12, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, xb.Append(&Rec{Name: "Abc", Flag: true, Count: 123, Price: 1.5, Date: d}))
+	require.NoError(t, xb.Append(&Rec{Name: "Def", Count: 7}))
+	require.NoError(t, xb.Close())
+
+	rd, err := New(NewSeekableBufferWithBytes(buf.Bytes()))
+	require.NoError(t, err)
+	// 1 + 20 + 1 + 5 + 9 + 8 = 44 bytes, padded to 48
+	require.Equal(t, uint16(48), rd.header.RecSize)
+	require.NoError(t, rd.Last())
+	require.Equal(t, "Def", rd.FieldValueAsString(1))
+	require.Equal(t, int64(7), rd.FieldValueAsInt(3))
+
+	xb, err = New(nil)
+	require.NoError(t, err)
+	xb.AddField("NAME", "C", 3)
+	xb.AddField("FLAG", "L")
+	xb.SetRecordAlignment(4)
+	require.Equal(t, uint16(8), xb.calcRecSize())
+}
+
+func TestRecode(t *testing.T) {
+	b, err := ioutil.ReadFile("./testdata/rec3.dbf")
+	require.NoError(t, err)
+	buf := NewSeekableBufferWithBytes(b)
+	db, err := New(buf)
+	require.NoError(t, err)
+	require.Equal(t, 866, db.CodePage())
+
+	require.NoError(t, db.GoTo(2))
+	require.NoError(t, db.Recode(1251))
+	require.Equal(t, 1251, db.CodePage())
+	require.Equal(t, int64(2), db.RecNo())
+	require.NoError(t, db.Close())
+
+	db, err = New(NewSeekableBufferWithBytes(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, 1251, db.CodePage())
+	require.NoError(t, db.GoTo(3))
+	require.Equal(t, "Мышь", db.FieldValueAsString(1))
+	require.Equal(t, []byte{0xcc, 0xfb, 0xf8, 0xfc}, db.FieldValueAsBytes(1)[:4])
+	require.NoError(t, db.GoTo(1))
+	require.Equal(t, "Abc", db.FieldValueAsString(1))
+}
+
+func TestRecodeUnmappable(t *testing.T) {
+	b, err := ioutil.ReadFile("./testdata/rec3.dbf")
+	require.NoError(t, err)
+	db, err := New(NewSeekableBufferWithBytes(b))
+	require.NoError(t, err)
+	// record 1 can be transcoded, record 3 can't
+	require.NoError(t, db.GoTo(1))
+	db.SetFieldValue(1, "20°")
+	require.NoError(t, db.Save())
+	require.NoError(t, db.GoTo(2))
+	before := append([]byte(nil), db.seeker().(*SeekableBuffer).Bytes()...)
+	require.Error(t, db.Recode(1252))
+	assert.Equal(t, before, db.seeker().(*SeekableBuffer).Bytes())
+	assert.Equal(t, 866, db.CodePage())
+	assert.Equal(t, int64(2), db.RecNo())
+
+	db, err = New(NewSeekableBufferWithBytes(b))
+	require.NoError(t, err)
+	db.SetOnUnmappable(UnmappableReplace)
+	require.NoError(t, db.Recode(1252))
+	require.NoError(t, db.GoTo(3))
+	require.Equal(t, "????", db.FieldValueAsString(1))
+}
+
+func TestFirstRecordIsHeader(t *testing.T) {
+	db, err := New(nil)
+	require.NoError(t, err)
+	db.AddField("NAME", "C", 10)
+	db.AddField("CITY", "C", 10)
+	require.NoError(t, db.CreateFile("./testdata/test-header.dbf"))
+	defer db.Close()
+	for _, row := range []interface{}{
+		[]interface{}{"Full Name", "Home City"},
+		[]interface{}{"Bob", "Oslo"},
+		[]interface{}{"Ann", "Rome"},
+	} {
+		require.NoError(t, db.Write(row.([]interface{})))
+	}
+
+	require.NoError(t, db.SetFirstRecordIsHeader(true))
+	require.Equal(t, []string{"Full Name", "Home City"}, db.HeaderNames())
+	require.NoError(t, db.First())
+	require.Equal(t, int64(2), db.RecNo())
+	require.Equal(t, "Bob", db.FieldValueAsString(1))
+	require.ErrorIs(t, db.Prev(), BOF)
+
+	require.NoError(t, db.SetFirstRecordIsHeader(false))
+	require.Equal(t, []string{"NAME", "CITY"}, db.HeaderNames())
+	require.NoError(t, db.First())
+	require.Equal(t, "Full Name", db.FieldValueAsString(1))
+}
+
+func TestFillRatios(t *testing.T) {
+	db, err := New(NewSeekableBuffer())
+	require.NoError(t, err)
+	for _, rec := range []*Rec{{Name: "a", Count: 1}, {Count: 2}, {Name: "c", Count: 3}, {Count: 4}, {Name: "del"}} {
+		require.NoError(t, db.Append(rec))
+	}
+	require.NoError(t, db.Last())
+	db.Del()
+	require.NoError(t, db.Save())
+
+	ratios, err := db.FillRatios()
+	require.NoError(t, err)
+	require.Equal(t, 0.5, ratios["NAME"])
+	require.Equal(t, 1.0, ratios["COUNT"])
+	require.Equal(t, 1.0, ratios["FLAG"])
+
+	// the first record {Name: "a"} is a header
+	require.NoError(t, db.SetFirstRecordIsHeader(true))
+	ratios, err = db.FillRatios()
+	require.NoError(t, err)
+	require.Equal(t, 1.0/3, ratios["NAME"])
+}
+
+func TestValidate(t *testing.T) {
+	b, err := ioutil.ReadFile("./testdata/rec3.dbf")
+	require.NoError(t, err)
+	db, err := New(NewSeekableBufferWithBytes(b))
+	require.NoError(t, err)
+	require.Empty(t, db.Validate())
+
+	bad := make([]byte, len(b)-10)
+	copy(bad, b)
+	bad[10]++                          // RecSize
+	bad[headerSize+fieldSize+11] = 'X' // type of 2nd field
+	db, err = New(NewSeekableBufferWithBytes(bad))
+	require.NoError(t, err)
+	errs := db.Validate()
+	require.Len(t, errs, 3)
+	require.Contains(t, errs[0].Error(), "record size")
+	require.Contains(t, errs[1].Error(), `unknown type "X"`)
+	require.Contains(t, errs[2].Error(), "file size")
+}
+
+func TestAutoIncNext(t *testing.T) {
+	db, err := New(nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AddField("ID", "+"))
+	require.NoError(t, db.AddField("NAME", "C", 10))
+	require.NoError(t, db.CreateFile("./testdata/test-autoinc.dbf"))
+
+	next, err := db.AutoIncNext("id")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), next)
+
+	require.NoError(t, db.Add())
+	db.SetFieldValue(2, "first")
+	require.NoError(t, db.Save())
+	require.Equal(t, int64(1), db.FieldValueAsInt(1))
+
+	require.NoError(t, db.SetAutoIncNext("ID", 100))
+	require.NoError(t, db.Add())
+	db.SetFieldValue(2, "second")
+	require.NoError(t, db.Save())
+	require.Equal(t, int64(100), db.FieldValueAsInt(1))
+	require.NoError(t, db.Close())
+	require.NoError(t, db.Error())
+
+	db, err = Open("./testdata/test-autoinc.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	next, err = db.AutoIncNext("ID")
+	require.NoError(t, err)
+	require.Equal(t, int64(101), next)
+	require.NoError(t, db.Last())
+	require.Equal(t, "100", db.FieldValueAsString(1))
+	require.Empty(t, db.Validate())
+
+	_, err = db.AutoIncNext("NAME")
+	require.Error(t, err)
+}
+
+func TestRecordBytes(t *testing.T) {
+	src, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer src.Close()
+
+	dst, err := New(nil)
+	require.NoError(t, err)
+	addFields(dst)
+	require.NoError(t, dst.CreateFile("./testdata/test-recbytes.dbf"))
+	for err = src.First(); err == nil; err = src.Next() {
+		require.NoError(t, dst.Add())
+		require.NoError(t, dst.SetRecordBytes(src.RecordBytes()))
+		require.NoError(t, dst.Save())
+	}
+	require.ErrorIs(t, err, io.EOF)
+	require.Error(t, dst.SetRecordBytes([]byte{' '}))
+	require.NoError(t, dst.Close())
+
+	testBytes := readFile("./testdata/test-recbytes.dbf")
+	goldBytes := readFile("./testdata/rec3.dbf")
+	require.Equal(t, goldBytes, testBytes)
+}
+
+func TestWriteToReadFrom(t *testing.T) {
+	gold, err := ioutil.ReadFile("./testdata/rec3.dbf")
+	require.NoError(t, err)
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var _ io.WriterTo = db
+	var buf bytes.Buffer
+	n, err := db.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(gold)), n)
+	require.Equal(t, gold, buf.Bytes())
+
+	var _ io.ReaderFrom = db
+	cp, err := New(nil)
+	require.NoError(t, err)
+	n, err = cp.ReadFrom(&buf)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(gold)), n)
+	require.Equal(t, int64(3), cp.RecCount())
+	require.NoError(t, cp.Last())
+	require.Equal(t, "Мышь", cp.FieldValueAsString(1))
+
+	// the previous file is closed, the options are kept
+	f, err := Open("./testdata/rec3.dbf", true, WithThreadSafe())
+	require.NoError(t, err)
+	f.SetLenientSet(true)
+	prev := f.seeker().(*os.File)
+	_, err = f.ReadFrom(bytes.NewReader(gold))
+	require.NoError(t, err)
+	assert.Error(t, prev.Close(), "file is still open")
+	assert.NotNil(t, f.mu)
+	assert.True(t, f.lenient)
+	assert.True(t, f.readOnly)
+	require.NoError(t, f.GoTo(3))
+	assert.Equal(t, "Мышь", f.FieldValueAsString(1))
+}
+
+func TestAppendStruct(t *testing.T) {
+	copyFile("./testdata/rec3.dbf", "./testdata/test-append.dbf")
+	db, err := Open("./testdata/test-append.dbf", false)
+	require.NoError(t, err)
+
+	require.NoError(t, db.AppendStruct(&Rec{Name: "four", Count: 4}))
+	require.NoError(t, db.AppendStruct(&Rec{Name: "five", Count: 5}))
+	require.Equal(t, int64(5), db.RecCount())
+	b := readFile("./testdata/test-append.dbf")
+	require.Equal(t, byte(3), b[4])
+
+	require.NoError(t, db.Flush())
+	b = readFile("./testdata/test-append.dbf")
+	require.Equal(t, byte(5), b[4])
+	require.Equal(t, fileEnd, b[len(b)-1])
+	require.NoError(t, db.Last())
+	require.Equal(t, "five", db.FieldValueAsString(1))
+	require.NoError(t, db.Close())
+}
+
+func BenchmarkAppendStruct(b *testing.B) {
+	rec := &Rec{Name: "Abc", Flag: true, Count: 123, Price: 123.45, Date: time.Date(2021, 2, 12, 0, 0, 0, 0, time.UTC)}
+	for i := 0; i < b.N; i++ {
+		f, err := os.Create("./testdata/test-bench.dbf")
+		if err != nil {
+			b.Fatal(err)
+		}
+		db, err := New(f)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for j := 0; j < 50000; j++ {
+			if err := db.AppendStruct(rec); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := db.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	copyFile("./testdata/rec3.dbf", "./testdata/test-truncate.dbf")
+	db, err := Open("./testdata/test-truncate.dbf", false)
+	require.NoError(t, err)
+	require.Error(t, db.Truncate(-1))
+	require.NoError(t, db.Truncate(5))
+	require.Equal(t, int64(3), db.RecCount())
+
+	require.NoError(t, db.Last())
+	require.NoError(t, db.Truncate(1))
+	require.Equal(t, int64(1), db.RecCount())
+	require.Equal(t, int64(1), db.RecNo())
+	require.Equal(t, "Abc", db.FieldValueAsString(1))
+	require.NoError(t, db.Close())
+
+	b := readFile("./testdata/test-truncate.dbf")
+	require.Len(t, b, int(db.header.DataOffset)+int(db.header.RecSize)+1)
+	require.Equal(t, fileEnd, b[len(b)-1])
+	gold := readFile("./testdata/rec3.dbf")
+	require.Equal(t, gold[headerSize:len(b)-1], b[headerSize:len(b)-1])
+
+	db, err = Open("./testdata/test-truncate.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	require.Equal(t, int64(1), db.RecCount())
+	require.Empty(t, db.Validate())
+}
+
+func TestNewFromReader(t *testing.T) {
+	gold, err := ioutil.ReadFile("./testdata/rec3.dbf")
+	require.NoError(t, err)
+	db, err := NewFromReader(bytes.NewReader(gold))
+	require.NoError(t, err)
+	require.Equal(t, int64(3), db.RecCount())
+
+	require.NoError(t, db.GoTo(2))
+	db.SetFieldValue(1, "Edit")
+	require.NoError(t, db.Save())
+	rec := httptest.NewRecorder()
+	n, err := db.WriteTo(rec)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(gold)), n)
+
+	db, err = NewFromReader(rec.Body)
+	require.NoError(t, err)
+	require.NoError(t, db.GoTo(2))
+	require.Equal(t, "Edit", db.FieldValueAsString(1))
+
+	_, err = NewFromReader(bytes.NewReader([]byte{0x05}))
+	require.Error(t, err)
+}
+
+func TestFieldTimestamp(t *testing.T) {
+	db, err := New(nil)
+	require.NoError(t, err)
+	db.AddField("TS", "C", 14)
+	db.AddField("SHORT", "C", 8)
+	require.NoError(t, db.CreateFile("./testdata/test-timestamp.dbf"))
+	defer db.Close()
+
+	ts := time.Date(2021, 2, 12, 14, 30, 0, 0, time.UTC)
+	require.NoError(t, db.Add())
+	db.SetFieldTimestamp(1, ts)
+	require.NoError(t, db.Save())
+	require.NoError(t, db.Add())
+	db.SetFieldTimestamp(1, time.Time{})
+	require.NoError(t, db.Save())
+	require.NoError(t, db.Error())
+
+	require.NoError(t, db.First())
+	require.Equal(t, "20210212143000", db.FieldValueAsString(1))
+	require.Equal(t, ts, db.FieldValueAsTimestamp(1))
+	require.NoError(t, db.Next())
+	require.True(t, db.FieldValueAsTimestamp(1).IsZero())
+	require.NoError(t, db.Error())
+
+	db.SetFieldTimestamp(2, ts)
+	require.Error(t, db.Error())
+}
+
+func TestFieldClock(t *testing.T) {
+	db, err := New(nil)
+	require.NoError(t, err)
+	db.AddField("AT", "C", 8)
+	db.AddField("HMS", "C", 6)
+	db.AddField("SHORT", "C", 4)
+	require.NoError(t, db.CreateFile("./testdata/test-clock.dbf"))
+	defer db.Close()
+
+	d := 12*time.Hour + 34*time.Minute + 56*time.Second + 789*time.Millisecond
+	require.NoError(t, db.Add())
+	db.SetFieldClock(1, d)
+	db.SetFieldClock(2, d)
+	require.NoError(t, db.Save())
+	require.NoError(t, db.Error())
+
+	require.NoError(t, db.First())
+	require.Equal(t, "12:34:56", db.FieldValueAsString(1))
+	require.Equal(t, "123456", db.FieldValueAsString(2))
+	require.Equal(t, d.Truncate(time.Second), db.FieldValueAsClock(1))
+	require.Equal(t, d.Truncate(time.Second), db.FieldValueAsClock(2))
+	require.Equal(t, time.Duration(0), db.FieldValueAsClock(3))
+	require.NoError(t, db.Error())
+
+	db.SetFieldClock(3, d)
+	require.Error(t, db.Error())
+	db.Clear()
+	db.SetFieldClock(1, 25*time.Hour)
+	require.Error(t, db.Error())
+}
+
+func TestFieldsOfType(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	require.Len(t, db.FieldInfos(), 5)
+	require.Equal(t, []FieldInfo{{Name: "COUNT", Type: 'N', Len: 5}}, db.FieldsOfType(FieldType_Numeric))
+	require.Equal(t, []FieldInfo{{Name: "PRICE", Type: 'F', Len: 9, Dec: 2}}, db.FieldsOfType(FieldType_Float))
+	require.Empty(t, db.FieldsOfType(FieldType_Memo))
+}
+
+func TestAggregate(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.First())
+	count := db.FieldNo("COUNT")
+
+	sum, err := db.Aggregate(count, AggSum)
+	require.NoError(t, err)
+	assert.Equal(t, float64(-198), sum)
+
+	n, err := db.Aggregate(count, AggCount)
+	require.NoError(t, err)
+	assert.Equal(t, float64(3), n)
+
+	min, err := db.Aggregate(count, AggMin)
+	require.NoError(t, err)
+	assert.Equal(t, float64(-321), min)
+
+	max, err := db.Aggregate(count, AggMax)
+	require.NoError(t, err)
+	assert.Equal(t, float64(123), max)
+
+	avg, err := db.Aggregate(count, AggAvg)
+	require.NoError(t, err)
+	assert.Equal(t, float64(-66), avg)
+
+	price, err := db.Aggregate(db.FieldNo("PRICE"), AggMax)
+	require.NoError(t, err)
+	assert.Equal(t, 123.45, price)
+
+	_, err = db.Aggregate(db.FieldNo("NAME"), AggSum)
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), db.RecNo())
+
+	require.NoError(t, db.SetFirstRecordIsHeader(true))
+	n, err = db.Aggregate(count, AggCount)
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), n)
+	sum, err = db.Aggregate(count, AggSum)
+	require.NoError(t, err)
+	assert.Equal(t, float64(-321), sum)
+}
+
+func TestGroupBy(t *testing.T) {
+	db := MustNew(nil)
+	require.NoError(t, db.AddField("CAT", "C", 5))
+	require.NoError(t, db.AddField("AMOUNT", "F", 8, 2))
+	require.NoError(t, db.CreateFile("./testdata/test-group.dbf"))
+	defer db.Close()
+
+	rows := []struct {
+		cat    string
+		amount float64
+		del    bool
+	}{
+		{"a", 1.5, false},
+		{"b", 10, false},
+		{"a", 2.25, false},
+		{"b", 100, true},
+		{"c", -3, false},
+	}
+	for _, r := range rows {
+		require.NoError(t, db.Add())
+		db.SetFieldValue(1, r.cat)
+		db.SetFieldValue(2, r.amount)
+		if r.del {
+			db.Del()
+		}
+		require.NoError(t, db.Save())
+	}
+
+	sums, err := db.GroupBy("cat", "amount", AggSum)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"a": 3.75, "b": 10, "c": -3}, sums)
+
+	counts, err := db.GroupBy("CAT", "AMOUNT", AggCount)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"a": 2, "b": 1, "c": 1}, counts)
+
+	_, err = db.GroupBy("NONE", "AMOUNT", AggSum)
+	assert.EqualError(t, err, `xbase: GroupBy: field "NONE" not found`)
+	_, err = db.GroupBy("AMOUNT", "CAT", AggSum)
+	assert.Error(t, err)
+
+	// the first row {"a", 1.5} is a header now
+	require.NoError(t, db.SetFirstRecordIsHeader(true))
+	sums, err = db.GroupBy("CAT", "AMOUNT", AggSum)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"a": 2.25, "b": 10, "c": -3}, sums)
+}
+
+func TestSetFieldTransform(t *testing.T) {
+	db := MustNew(nil)
+	addFields(db)
+	require.NoError(t, db.CreateFile("./testdata/test-transform.dbf"))
+	defer db.Close()
+
+	db.SetFieldTransform(1, func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("want string, got %T", v)
+		}
+		return strings.ToUpper(strings.TrimSpace(s)), nil
+	})
+	require.NoError(t, db.Error())
+
+	require.NoError(t, db.Add())
+	db.SetFieldValue(1, "  abc ")
+	require.NoError(t, db.Error())
+	require.NoError(t, db.Save())
+	assert.Equal(t, "ABC", db.FieldValueAsString(1))
+
+	require.NoError(t, db.Write([]interface{}{"xyz", true}))
+	require.NoError(t, db.Last())
+	assert.Equal(t, "XYZ", db.FieldValueAsString(1))
+
+	require.NoError(t, db.Add())
+	db.SetFieldValue(1, 1)
+	assert.EqualError(t, db.Error(), `xbase: SetFieldValue: field 1 "NAME": want string, got int`)
+	db.Clear()
+
+	db.SetFieldTransform(1, nil)
+	require.NoError(t, db.Add())
+	db.SetFieldValue(1, "abc")
+	require.NoError(t, db.Save())
+	assert.Equal(t, "abc", db.FieldValueAsString(1))
+
+	db.SetFieldTransform(9, nil)
+	assert.Error(t, db.Error())
+}
+
+func TestFieldValue(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.First())
+
+	assert.Equal(t, "Abc", db.FieldValue(1))
+	assert.Equal(t, true, db.FieldValue(2))
+	assert.Equal(t, int64(123), db.FieldValue(3))
+	assert.Equal(t, 123.45, db.FieldValue(4))
+	assert.Equal(t, time.Date(2021, 2, 12, 0, 0, 0, 0, time.UTC), db.FieldValue(5))
+	require.NoError(t, db.Error())
+
+	require.NoError(t, db.Next())
+	assert.Equal(t, "", db.FieldValue(1))
+	assert.Nil(t, db.FieldValue(3))
+	assert.Nil(t, db.FieldValue(4))
+	assert.Nil(t, db.FieldValue(5))
+	require.NoError(t, db.Error())
+
+	require.NoError(t, db.Next())
+	assert.Equal(t, "Мышь", db.FieldValue(1))
+	assert.Equal(t, int64(-321), db.FieldValue(3))
+
+	assert.Nil(t, db.FieldValue(6))
+	assert.Error(t, db.Error())
+}
+
+func TestTruncatedRecord(t *testing.T) {
+	b := readFile("./testdata/rec3.dbf")
+	// cut the record size to drop the last byte of the DATE field
+	recSize := binary.LittleEndian.Uint16(b[10:12])
+	binary.LittleEndian.PutUint16(b[10:12], recSize-1)
+
+	db, err := NewFromReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	require.NoError(t, db.First())
+
+	assert.Equal(t, "Abc", db.FieldValueAsString(1))
+	require.NoError(t, db.Error())
+	db.FieldValueAsDate(5)
+	assert.ErrorIs(t, db.Error(), ErrTruncatedRecord)
+	assert.EqualError(t, db.Error(), `xbase: FieldValueAsDate: field 5 "DATE": xbase: record is shorter than the field layout`)
+	db.Clear()
+
+	_, err = db.Read()
+	require.NoError(t, err)
+	_, err = db.Read()
+	assert.ErrorIs(t, err, ErrTruncatedRecord)
+	_, err = db.Aggregate(3, AggSum)
+	assert.ErrorIs(t, err, ErrTruncatedRecord)
+
+	// writes fail with the error too
+	db, err = New(NewSeekableBufferWithBytes(b))
+	require.NoError(t, err)
+	_, err = db.ReplaceAll(5, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{})
+	assert.ErrorIs(t, err, ErrTruncatedRecord)
+	assert.ErrorIs(t, db.AddFieldMigrate("NOTE", "C", 5), ErrTruncatedRecord)
+	assert.Equal(t, b, db.rws.(*SeekableBuffer).Bytes())
+	dst, err := New(NewSeekableBufferWithBytes(readFile("./testdata/rec3.dbf")))
+	require.NoError(t, err)
+	_, err = dst.CopyRecordsFrom(db, nil)
+	assert.ErrorIs(t, err, ErrTruncatedRecord)
+}
+
+func TestClone(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.First())
+
+	c, err := db.Clone()
+	require.NoError(t, err)
+	require.NoError(t, c.Last())
+	assert.Equal(t, "Мышь", c.FieldValueAsString(1))
+
+	require.NoError(t, db.Next())
+	assert.Equal(t, int64(2), db.RecNo())
+	assert.Equal(t, int64(3), c.RecNo())
+	require.NoError(t, c.Prev())
+	require.NoError(t, c.Prev())
+	assert.Equal(t, "Abc", strings.TrimSpace(c.FieldValueAsString(1)))
+	require.NoError(t, c.Close())
+
+	require.NoError(t, db.Next())
+	assert.Equal(t, int64(-321), db.FieldValueAsInt(3))
+	require.NoError(t, db.Error())
+
+	mem, err := NewFromReader(bytes.NewReader(readFile("./testdata/rec3.dbf")))
+	require.NoError(t, err)
+	mc, err := mem.Clone()
+	require.NoError(t, err)
+	require.NoError(t, mc.First())
+	assert.Equal(t, int64(123), mc.FieldValueAsInt(3))
+	assert.Equal(t, int64(0), mem.RecNo())
+
+	_, err = MustNew(nil).Clone()
+	assert.Error(t, err)
+
+	// a wrong record size in the header
+	b := readFile("./testdata/rec3.dbf")
+	binary.LittleEndian.PutUint16(b[10:12], binary.LittleEndian.Uint16(b[10:12])-1)
+	rc, err := New(NewSeekableBufferWithBytes(b), WithRecomputeRecSize())
+	require.NoError(t, err)
+	rc.SetLenientSet(true)
+	rc.SetOverflowAsNull(true)
+	require.NoError(t, rc.SetLogicalFormat('Y', 'N'))
+	rcc, err := rc.Clone()
+	require.NoError(t, err)
+	require.NoError(t, rcc.GoTo(3))
+	assert.Equal(t, "Мышь", rcc.FieldValueAsString(1))
+	assert.True(t, rcc.lenient)
+	assert.True(t, rcc.overflowAsNull)
+	assert.Equal(t, [2]byte{'Y', 'N'}, rcc.logical)
+}
+
+func TestSetLenientSet(t *testing.T) {
+	db := MustNew(nil)
+	addFields(db)
+	require.NoError(t, db.CreateFile("./testdata/test-lenient.dbf"))
+	defer db.Close()
+	require.NoError(t, db.Add())
+
+	db.SetFieldValue(4, "12.5")
+	assert.Error(t, db.Error())
+	db.Clear()
+	require.NoError(t, db.Add())
+	db.SetFieldValue(4, 7)
+	assert.Error(t, db.Error())
+	db.Clear()
+	require.NoError(t, db.Add())
+	db.SetFieldValue(3, 12.6)
+	assert.Error(t, db.Error())
+	db.Clear()
+	require.NoError(t, db.Add())
+	db.SetFieldValue(1, 12)
+	assert.Error(t, db.Error())
+	db.Clear()
+
+	db.SetLenientSet(true)
+	require.NoError(t, db.Add())
+	db.SetFieldValue(1, 12.5)
+	db.SetFieldValue(2, "yes")
+	db.SetFieldValue(3, 12.6)
+	db.SetFieldValue(4, 7)
+	db.SetFieldValue(5, "20210212")
+	require.NoError(t, db.Error())
+	require.NoError(t, db.Save())
+	assert.Equal(t, "12.5", strings.TrimSpace(db.FieldValueAsString(1)))
+	assert.Equal(t, true, db.FieldValueAsBool(2))
+	assert.Equal(t, int64(13), db.FieldValueAsInt(3))
+	assert.Equal(t, 7.0, db.FieldValueAsFloat(4))
+	assert.Equal(t, time.Date(2021, 2, 12, 0, 0, 0, 0, time.UTC), db.FieldValueAsDate(5))
+
+	db.SetFieldValue(4, "12.5")
+	assert.Equal(t, 12.5, db.FieldValueAsFloat(4))
+	db.SetFieldValue(4, "abc")
+	assert.Error(t, db.Error())
+}
+
+func TestTrailingBytes(t *testing.T) {
+	trailing := []byte("INDEX BLOCK")
+	b := append(readFile("./testdata/rec3.dbf"), trailing...)
+	require.NoError(t, ioutil.WriteFile("./testdata/test-trailing.dbf", b, 0644))
+
+	db, err := Open("./testdata/test-trailing.dbf", false)
+	require.NoError(t, err)
+	n, err := db.TrailingBytes()
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(trailing)), n)
+
+	require.NoError(t, db.First())
+	db.SetFieldValue(1, "Xyz")
+	require.NoError(t, db.Save())
+	require.NoError(t, db.Close())
+
+	got := readFile("./testdata/test-trailing.dbf")
+	require.Len(t, got, len(b))
+	assert.Equal(t, trailing, got[len(got)-len(trailing):])
+	assert.Equal(t, byte(0x1A), got[len(got)-len(trailing)-1])
+
+	db, err = Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	n, err = db.TrailingBytes()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
+func TestPreserveTrailing(t *testing.T) {
+	trailing := []byte("INDEX BLOCK")
+	rec3 := readFile("./testdata/rec3.dbf")
+	b := append(append([]byte(nil), rec3...), trailing...)
+	require.NoError(t, ioutil.WriteFile("./testdata/test-preserve.dbf", b, 0644))
+
+	db, err := Open("./testdata/test-preserve.dbf", false)
+	require.NoError(t, err)
+	require.NoError(t, db.Truncate(2))
+	require.NoError(t, db.Add())
+	db.SetFieldValue(1, "New")
+	require.NoError(t, db.Save())
+	require.NoError(t, db.Close())
+
+	got := readFile("./testdata/test-preserve.dbf")
+	assert.Equal(t, trailing, got[len(got)-len(trailing):])
+	assert.Equal(t, byte(0x1A), got[len(got)-len(trailing)-1])
+	db, err = Open("./testdata/test-preserve.dbf", true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), db.RecCount())
+	n, err := db.TrailingBytes()
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(trailing)), n)
+	require.NoError(t, db.Last())
+	assert.Equal(t, "New", strings.TrimSpace(db.FieldValueAsString(1)))
+	require.NoError(t, db.Close())
+
+	// the trailing data is written back once after the appended records
+	require.NoError(t, ioutil.WriteFile("./testdata/test-preserve.dbf", b, 0644))
+	db, err = Open("./testdata/test-preserve.dbf", false)
+	require.NoError(t, err)
+	for _, name := range []string{"New1", "New2", "New3"} {
+		require.NoError(t, db.Add())
+		db.SetFieldValue(1, name)
+		require.NoError(t, db.Save())
+		n, err = db.TrailingBytes()
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(trailing)), n)
+	}
+	require.NoError(t, db.Close())
+	got = readFile("./testdata/test-preserve.dbf")
+	assert.Len(t, got, len(b)+3*int(db.header.RecSize))
+	assert.Equal(t, trailing, got[len(got)-len(trailing):])
+	assert.Equal(t, byte(0x1A), got[len(got)-len(trailing)-1])
+	db, err = Open("./testdata/test-preserve.dbf", true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), db.RecCount())
+	require.NoError(t, db.Last())
+	assert.Equal(t, "New3", db.FieldValueAsString(1))
+	require.NoError(t, db.Close())
+
+	require.NoError(t, ioutil.WriteFile("./testdata/test-preserve.dbf", b, 0644))
+	db, err = Open("./testdata/test-preserve.dbf", false)
+	require.NoError(t, err)
+	db.SetPreserveTrailing(false)
+	require.NoError(t, db.Truncate(2))
+	require.NoError(t, db.Close())
+	assert.Equal(t, len(rec3)-int(db.header.RecSize), len(readFile("./testdata/test-preserve.dbf")))
+}
+
+func TestDoubleField(t *testing.T) {
+	db := MustNew(nil)
+	require.NoError(t, db.AddField("NAME", "C", 5))
+	require.NoError(t, db.AddField("RATIO", "B"))
+	require.NoError(t, db.CreateFile("./testdata/test-double.dbf"))
+	for _, v := range []float64{1.5, -0.001, 1e300} {
+		require.NoError(t, db.Add())
+		db.SetFieldValue(2, v)
+		require.NoError(t, db.Save())
+	}
+	require.NoError(t, db.Close())
+
+	db, err := Open("./testdata/test-double.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, byte('B'), db.FieldInfos()[1].Type)
+	assert.Equal(t, 8, db.FieldInfos()[1].Len)
+	require.NoError(t, db.First())
+	assert.Equal(t, 1.5, db.FieldValueAsFloat(2))
+	assert.Equal(t, 1.5, db.FieldValue(2))
+	require.NoError(t, db.Next())
+	assert.Equal(t, -0.001, db.FieldValueAsFloat(2))
+	require.NoError(t, db.Next())
+	assert.Equal(t, 1e300, db.FieldValueAsFloat(2))
+	require.NoError(t, db.Error())
+
+	sum, err := db.Aggregate(2, AggMin)
+	require.NoError(t, err)
+	assert.Equal(t, -0.001, sum)
+}
+
+func TestDeletedCount(t *testing.T) {
+	copyFile("./testdata/rec3.dbf", "./testdata/test-deleted.dbf")
+	db, err := Open("./testdata/test-deleted.dbf", false)
+	require.NoError(t, err)
+	defer db.Close()
+
+	n, err := db.DeletedCount()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+
+	for _, recNo := range []int64{1, 3} {
+		require.NoError(t, db.GoTo(recNo))
+		db.Del()
+		require.NoError(t, db.Save())
+	}
+	require.NoError(t, db.GoTo(2))
+
+	n, err = db.DeletedCount()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	n, err = db.LiveCount()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, int64(2), db.RecNo())
+	assert.False(t, db.RecDeleted())
+}
+
+func TestOpenGz(t *testing.T) {
+	f, err := os.Create("./testdata/test-rec3.dbf.gz")
+	require.NoError(t, err)
+	zw := gzip.NewWriter(f)
+	_, err = zw.Write(readFile("./testdata/rec3.dbf"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+	defer os.Remove("./testdata/test-rec3.dbf.gz")
+
+	db, err := OpenGz("./testdata/test-rec3.dbf.gz")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), db.RecCount())
+	require.NoError(t, db.Last())
+	assert.Equal(t, "Мышь", strings.TrimSpace(db.FieldValueAsString(1)))
+
+	db.SetFieldValue(1, "Edit")
+	assert.ErrorIs(t, db.Save(), ErrReadOnly)
+	require.NoError(t, db.Add())
+	assert.ErrorIs(t, db.Save(), ErrReadOnly)
+	require.NoError(t, db.Close())
+
+	_, err = OpenGz("./testdata/rec3.dbf")
+	assert.Error(t, err)
+}
+
+func TestNextDeleted(t *testing.T) {
+	db := MustNew(nil)
+	require.NoError(t, db.AddField("ID", "N", 3))
+	require.NoError(t, db.CreateFile("./testdata/test-nextdel.dbf"))
+	defer db.Close()
+	for i := 1; i <= 6; i++ {
+		require.NoError(t, db.Add())
+		db.SetFieldValue(1, i)
+		if i%3 != 1 {
+			db.Del()
+		}
+		require.NoError(t, db.Save())
+	}
+
+	var got []int64
+	require.NoError(t, db.First())
+	got = append(got, db.FieldValueAsInt(1))
+	for {
+		err := db.NextDeleted()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.True(t, db.RecDeleted())
+		got = append(got, db.FieldValueAsInt(1))
+	}
+	assert.Equal(t, []int64{1, 2, 3, 5, 6}, got)
+	assert.Equal(t, int64(6), db.RecNo())
+
+	require.NoError(t, db.GoTo(3))
+	require.NoError(t, db.Next())
+	assert.Equal(t, int64(4), db.FieldValueAsInt(1))
+	require.NoError(t, db.NextDeleted())
+	assert.Equal(t, int64(5), db.RecNo())
+}
+
+func TestPreview(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.Last())
+
+	fields, rows, err := db.Preview(2)
+	require.NoError(t, err)
+	assert.Equal(t, []FieldInfo{
+		{Name: "NAME", Type: 'C', Len: 20},
+		{Name: "FLAG", Type: 'L', Len: 1},
+		{Name: "COUNT", Type: 'N', Len: 5},
+		{Name: "PRICE", Type: 'F', Len: 9, Dec: 2},
+		{Name: "DATE", Type: 'D', Len: 8},
+	}, fields)
+	assert.Equal(t, [][]string{
+		{"Abc", "T", "123", "123.45", "20210212"},
+		{"", "", "", "", ""},
+	}, rows)
+	assert.Equal(t, int64(3), db.RecNo())
+
+	_, rows, err = db.Preview(10)
+	require.NoError(t, err)
+	assert.Len(t, rows, 3)
+
+	_, _, err = db.Preview(-1)
+	assert.EqualError(t, err, "xbase: Preview: negative count -1")
+}
+
+func TestSetLogicalFormat(t *testing.T) {
+	db := MustNew(nil)
+	require.NoError(t, db.AddField("FLAG", "L"))
+	require.NoError(t, db.CreateFile("./testdata/test-logical.dbf"))
+	defer db.Close()
+
+	assert.Error(t, db.SetLogicalFormat('X', 'N'))
+	assert.Error(t, db.SetLogicalFormat('Y', 'T'))
+	require.NoError(t, db.SetLogicalFormat('Y', 'N'))
+
+	for _, v := range []bool{true, false} {
+		require.NoError(t, db.Add())
+		db.SetFieldValue(1, v)
+		require.NoError(t, db.Save())
+	}
+	require.NoError(t, db.First())
+	assert.Equal(t, byte('Y'), db.RecordBytes()[1])
+	assert.True(t, db.FieldValueAsBool(1))
+	require.NoError(t, db.Next())
+	assert.Equal(t, byte('N'), db.RecordBytes()[1])
+	assert.False(t, db.FieldValueAsBool(1))
+	require.NoError(t, db.Error())
+}
+
+func TestRenameField(t *testing.T) {
+	copyFile("./testdata/rec3.dbf", "./testdata/test-rename.dbf")
+	db, err := Open("./testdata/test-rename.dbf", true)
+	require.NoError(t, err)
+	assert.ErrorIs(t, db.RenameField("COUNT", "QTY"), ErrReadOnly)
+	require.NoError(t, db.Close())
+
+	db, err = Open("./testdata/test-rename.dbf", false)
+	require.NoError(t, err)
+	assert.Error(t, db.RenameField("NONE", "QTY"))
+	assert.Error(t, db.RenameField("COUNT", "PRICE"))
+	assert.Error(t, db.RenameField("COUNT", "TOO_LONG_NAME"))
+	require.NoError(t, db.RenameField("count", "qty"))
+	require.NoError(t, db.Close())
+
+	db, err = Open("./testdata/test-rename.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, []string{"NAME", "FLAG", "QTY", "PRICE", "DATE"}, db.Fields())
+	require.NoError(t, db.Last())
+	assert.Equal(t, int64(-321), db.FieldValueAsInt(db.FieldNo("QTY")))
+}
+
+func TestProductionIndexName(t *testing.T) {
+	b := readFile("./testdata/rec3.dbf")
+	require.NoError(t, ioutil.WriteFile("./testdata/test-mdx.dbf", b, 0644))
+	db, err := Open("./testdata/test-mdx.dbf", true)
+	require.NoError(t, err)
+	assert.Equal(t, "", db.ProductionIndexName())
+	require.NoError(t, db.Close())
+
+	b[28] |= 0x01
+	require.NoError(t, ioutil.WriteFile("./testdata/test-mdx.dbf", b, 0644))
+	db, err = Open("./testdata/test-mdx.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, "./testdata/test-mdx.mdx", db.ProductionIndexName())
+	assert.False(t, db.HasProductionIndexFile())
+
+	require.NoError(t, ioutil.WriteFile("./testdata/test-mdx.mdx", nil, 0644))
+	defer os.Remove("./testdata/test-mdx.mdx")
+	assert.True(t, db.HasProductionIndexFile())
+}
+
+func TestAddFieldMigrate(t *testing.T) {
+	copyFile("./testdata/rec3.dbf", "./testdata/test-migrate.dbf")
+	db, err := Open("./testdata/test-migrate.dbf", false)
+	require.NoError(t, err)
+	require.NoError(t, db.GoTo(3))
+	assert.Error(t, db.AddFieldMigrate("NAME", "C", 5))
+	require.NoError(t, db.AddFieldMigrate("NOTE", "C", 6))
+	assert.Equal(t, int64(3), db.RecNo())
+	assert.Equal(t, "Мышь", strings.TrimSpace(db.FieldValueAsString(1)))
+	db.SetFieldValue(6, "note")
+	require.NoError(t, db.Save())
+	require.NoError(t, db.Close())
+
+	db, err = Open("./testdata/test-migrate.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Empty(t, db.Validate())
+	assert.Equal(t, []string{"NAME", "FLAG", "COUNT", "PRICE", "DATE", "NOTE"}, db.Fields())
+	assert.Equal(t, int64(3), db.RecCount())
+
+	require.NoError(t, db.First())
+	assert.Equal(t, "Abc", strings.TrimSpace(db.FieldValueAsString(1)))
+	assert.Equal(t, true, db.FieldValueAsBool(2))
+	assert.Equal(t, int64(123), db.FieldValueAsInt(3))
+	assert.Equal(t, 123.45, db.FieldValueAsFloat(4))
+	assert.Equal(t, "", strings.TrimSpace(db.FieldValueAsString(6)))
+	require.NoError(t, db.Last())
+	assert.Equal(t, int64(-321), db.FieldValueAsInt(3))
+	assert.Equal(t, "note", strings.TrimSpace(db.FieldValueAsString(6)))
+	require.NoError(t, db.Error())
+}
+
+func TestSetRecNoField(t *testing.T) {
+	db := MustNew(nil)
+	require.NoError(t, db.AddField("RECNO", "N", 6))
+	require.NoError(t, db.AddField("NAME", "C", 5))
+	require.NoError(t, db.CreateFile("./testdata/test-recno.dbf"))
+	defer db.Close()
+
+	assert.Error(t, db.SetRecNoField("NONE"))
+	assert.Error(t, db.SetRecNoField("NAME"))
+	require.NoError(t, db.SetRecNoField("recno"))
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, db.Add())
+		db.SetFieldValue(2, name)
+		require.NoError(t, db.Save())
+	}
+
+	var got []int64
+	require.NoError(t, db.First())
+	for !db.EOF() {
+		got = append(got, db.FieldValueAsInt(1))
+		if err := db.Next(); err != nil {
+			break
+		}
+	}
+	assert.Equal(t, []int64{1, 2, 3}, got)
+}
+
+func TestReadAllMaps(t *testing.T) {
+	copyFile("./testdata/rec3.dbf", "./testdata/test-maps.dbf")
+	db, err := Open("./testdata/test-maps.dbf", false)
+	require.NoError(t, err)
+	defer db.Close()
+
+	maps, err := db.ReadAllMaps()
+	require.NoError(t, err)
+	require.Len(t, maps, 3)
+	assert.Equal(t, map[string]interface{}{
+		"NAME":  "Abc",
+		"FLAG":  true,
+		"COUNT": int64(123),
+		"PRICE": 123.45,
+		"DATE":  time.Date(2021, 2, 12, 0, 0, 0, 0, time.UTC),
+	}, maps[0])
+	assert.Nil(t, maps[1]["COUNT"])
+	assert.Equal(t, "Мышь", maps[2]["NAME"])
+
+	require.NoError(t, db.GoTo(2))
+	db.Del()
+	require.NoError(t, db.Save())
+	m, err := db.RecordMap()
+	require.NoError(t, err)
+	assert.Equal(t, "", m["NAME"])
+	maps, err = db.ReadAllMaps()
+	require.NoError(t, err)
+	require.Len(t, maps, 2)
+	assert.Equal(t, int64(-321), maps[1]["COUNT"])
+	assert.Equal(t, int64(2), db.RecNo())
+}
+
+func TestDropField(t *testing.T) {
+	copyFile("./testdata/rec3.dbf", "./testdata/test-drop.dbf")
+	db, err := Open("./testdata/test-drop.dbf", false)
+	require.NoError(t, err)
+	require.NoError(t, db.First())
+	var r Rec
+	require.NoError(t, db.DecodeRecord(&r))
+	assert.True(t, r.Flag)
+
+	assert.Error(t, db.DropField("NONE"))
+	require.NoError(t, db.GoTo(3))
+	require.NoError(t, db.DropField("flag"))
+	assert.Equal(t, []string{"NAME", "COUNT", "PRICE", "DATE"}, db.Fields())
+	assert.Equal(t, int64(-321), db.FieldValueAsInt(2))
+
+	require.NoError(t, db.First())
+	r = Rec{}
+	require.NoError(t, db.DecodeRecord(&r))
+	assert.Equal(t, Rec{Name: "Abc", Count: 123, Price: 123.45, Date: time.Date(2021, 2, 12, 0, 0, 0, 0, time.UTC)}, r)
+	require.NoError(t, db.Close())
+
+	db, err = Open("./testdata/test-drop.dbf", false)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Empty(t, db.Validate())
+	assert.Equal(t, int64(3), db.RecCount())
+	require.NoError(t, db.Last())
+	assert.Equal(t, "Мышь", strings.TrimSpace(db.FieldValueAsString(1)))
+	assert.Equal(t, int64(-321), db.FieldValueAsInt(2))
+	assert.Equal(t, time.Date(2021, 2, 12, 0, 0, 0, 0, time.UTC), db.FieldValueAsDate(4))
+
+	require.NoError(t, db.DropField("NAME"))
+	require.NoError(t, db.DropField("COUNT"))
+	require.NoError(t, db.DropField("PRICE"))
+	assert.Error(t, db.DropField("DATE"))
+}
+
+func createIndexTestFile(tb testing.TB, name string, n int) *XBase {
+	db := MustNew(nil)
+	require.NoError(tb, db.AddField("ID", "N", 8))
+	require.NoError(tb, db.AddField("CODE", "C", 10))
+	require.NoError(tb, db.CreateFile(name))
+	for i := 0; i < n; i++ {
+		require.NoError(tb, db.Add())
+		db.SetFieldValue(1, (i*7919)%n)
+		db.SetFieldValue(2, fmt.Sprintf("K%d", i%3))
+		require.NoError(tb, db.Save())
+	}
+	require.NoError(tb, db.Flush())
+	return db
+}
+
+func TestBuildIndex(t *testing.T) {
+	db := createIndexTestFile(t, "./testdata/test-index.dbf", 10)
+	defer db.Close()
+
+	idx, err := db.BuildIndex(1)
+	require.NoError(t, err)
+	assert.Equal(t, 10, idx.Len())
+	recNo, ok := idx.Seek(3)
+	require.True(t, ok)
+	assert.Equal(t, recNo, db.RecNo())
+	assert.Equal(t, int64(3), db.FieldValueAsInt(1))
+	_, ok = idx.Next()
+	assert.False(t, ok)
+	_, ok = idx.Seek(42)
+	assert.False(t, ok)
+	assert.Equal(t, recNo, db.RecNo())
+	_, ok = idx.Seek("3")
+	assert.False(t, ok)
+
+	idx, err = db.BuildIndex(2)
+	require.NoError(t, err)
+	var got []int64
+	recNo, ok = idx.Seek("K1")
+	for ok {
+		assert.Equal(t, "K1", strings.TrimSpace(db.FieldValueAsString(2)))
+		got = append(got, recNo)
+		recNo, ok = idx.Next()
+	}
+	assert.Equal(t, []int64{2, 5, 8}, got)
+
+	_, err = db.BuildIndex(3)
+	assert.Error(t, err)
+}
+
+func BenchmarkIndexSeek(b *testing.B) {
+	const n = 10000
+	db := createIndexTestFile(b, "./testdata/test-index-bench.dbf", n)
+	defer db.Close()
+	idx, err := db.BuildIndex(1)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := idx.Seek(i % n); !ok {
+			b.Fatal("not found")
+		}
+	}
+}
+
+func BenchmarkScanSeek(b *testing.B) {
+	const n = 10000
+	db := createIndexTestFile(b, "./testdata/test-index-bench.dbf", n)
+	defer db.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		found := false
+		for err := db.First(); err == nil; err = db.Next() {
+			if db.FieldValueAsInt(1) == int64(i%n) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			b.Fatal("not found")
+		}
+	}
+}
+
+func TestReadWindow(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+
+	rows, err := db.ReadWindow(1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"", "", "", "", ""}}, rows)
+	rows, err = db.ReadWindow(2, 5)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "Мышь", rows[0][0])
+	rows, err = db.ReadWindow(3, 5)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+	_, err = db.ReadWindow(-1, 1)
+	assert.Error(t, err)
+
+	db = createIndexTestFile(t, "./testdata/test-window.dbf", 6)
+	defer db.Close()
+	for _, recNo := range []int64{1, 3} {
+		require.NoError(t, db.GoTo(recNo))
+		db.Del()
+		require.NoError(t, db.Save())
+	}
+	rows, err = db.ReadWindow(1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"3", "K0"}, {"2", "K1"}}, rows)
+}
+
+func TestWriteAt(t *testing.T) {
+	db := MustNew(nil)
+	addFields(db)
+	require.NoError(t, db.CreateFile("./testdata/test-writeat.dbf"))
+	defer db.Close()
+
+	assert.Error(t, db.WriteAt(0, nil))
+	assert.Error(t, db.WriteAt(1, map[string]interface{}{"NONE": 1}))
+	require.NoError(t, db.WriteAt(5, map[string]interface{}{"name": "Five", "COUNT": 5}))
+	assert.Equal(t, int64(5), db.RecCount())
+	assert.Equal(t, int64(5), db.RecNo())
+
+	for recNo := int64(1); recNo < 5; recNo++ {
+		require.NoError(t, db.GoTo(recNo))
+		assert.False(t, db.RecDeleted())
+		assert.Equal(t, strings.Repeat(" ", len(db.buffer)), string(db.RecordBytes()))
+	}
+	require.NoError(t, db.GoTo(5))
+	assert.Equal(t, "Five", strings.TrimSpace(db.FieldValueAsString(1)))
+	assert.Equal(t, int64(5), db.FieldValueAsInt(3))
+
+	require.NoError(t, db.WriteAt(2, map[string]interface{}{"NAME": "Two"}))
+	assert.Equal(t, int64(5), db.RecCount())
+	require.NoError(t, db.GoTo(2))
+	assert.Equal(t, "Two", strings.TrimSpace(db.FieldValueAsString(1)))
+
+	assert.Error(t, db.WriteAt(6, map[string]interface{}{"FLAG": "x"}))
+	assert.Equal(t, int64(5), db.RecCount())
+	require.NoError(t, db.Error())
+
+	require.NoError(t, db.Add())
+	assert.EqualError(t, db.WriteAt(1, nil), "xbase: WriteAt: current record is in add mode, save it first")
+	assert.EqualError(t, db.Compact(""), "xbase: Compact: current record is in add mode, save it first")
+	_, err := db.ImportCSVStream(strings.NewReader(""))
+	assert.EqualError(t, err, "xbase: ImportCSVStream: current record is in add mode, save it first")
+}
+
+func TestHeaderFlags(t *testing.T) {
+	db := MustNew(nil)
+	require.NoError(t, db.AddField("NAME", "C", 5))
+	assert.Error(t, db.AddField("NOTE", "M", 10))
+	assert.False(t, db.HasMemo())
+	db.SetHasMemo(true)
+	require.NoError(t, db.CreateFile("./testdata/test-flags.dbf"))
+	assert.True(t, db.HasMemo())
+	assert.False(t, db.HasMDX())
+	db.SetHasMDX(true)
+	require.NoError(t, db.Close())
+
+	b := readFile("./testdata/test-flags.dbf")
+	assert.Equal(t, byte(0x03), b[28])
+
+	db, err := Open("./testdata/test-flags.dbf", false)
+	require.NoError(t, err)
+	assert.True(t, db.HasMDX())
+	assert.True(t, db.HasMemo())
+	db.SetHasMDX(false)
+	require.NoError(t, db.Close())
+	assert.Equal(t, byte(0x02), readFile("./testdata/test-flags.dbf")[28])
+}
+
+func TestFieldValueAsDecimal(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.First())
+	assert.Equal(t, "123.45", db.FieldValueAsDecimalString(4))
+	assert.Equal(t, "123", db.FieldValueAsDecimalString(3))
+	assert.Equal(t, big.NewRat(12345, 100), db.FieldValueAsRat(4))
+	require.NoError(t, db.Error())
+	require.NoError(t, db.Next())
+	assert.Equal(t, "", db.FieldValueAsDecimalString(4))
+	assert.Nil(t, db.FieldValueAsRat(4))
+	db.FieldValueAsDecimalString(1)
+	assert.Error(t, db.Error())
+}
+
+func TestTolerantHeader(t *testing.T) {
+	b, err := ioutil.ReadFile("./testdata/rec3.dbf")
+	require.NoError(t, err)
+	junk := append([]byte{0xEF, 0xBB, 0xBF}, b...)
+
+	_, err = New(NewSeekableBufferWithBytes(junk))
+	require.Error(t, err)
+
+	buf := NewSeekableBufferWithBytes(junk)
+	db, err := New(buf, WithTolerantHeader())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), db.HeaderOffset())
+	assert.Empty(t, db.Validate())
+	require.NoError(t, db.GoTo(3))
+	assert.Equal(t, "Мышь", db.FieldValueAsString(1))
+	assert.Equal(t, int64(-321), db.FieldValueAsInt(3))
+
+	require.NoError(t, db.First())
+	db.SetFieldValue(1, "Xyz")
+	require.NoError(t, db.Save())
+	require.NoError(t, db.Flush())
+	assert.Equal(t, junk[:3], buf.Bytes()[:3])
+	assert.Equal(t, len(junk), len(buf.Bytes()))
+
+	db, err = New(NewSeekableBufferWithBytes(buf.Bytes()), WithTolerantHeader())
+	require.NoError(t, err)
+	require.NoError(t, db.First())
+	assert.Equal(t, "Xyz", db.FieldValueAsString(1))
+}
+
+func TestFieldLengthDecimalsType(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, 9, db.FieldLength(4))
+	assert.Equal(t, 2, db.FieldDecimals(4))
+	assert.Equal(t, byte(FieldType_Float), db.FieldType(4))
+	assert.Equal(t, byte(FieldType_Logical), db.FieldType(2))
+	require.NoError(t, db.Error())
+
+	assert.Equal(t, 0, db.FieldLength(10))
+	assert.EqualError(t, db.Error(), "xbase: FieldLength: field 10: field number out of range")
+}
+
+func TestFieldMaxMinInt(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, int64(99999), db.FieldMaxInt(3))
+	assert.Equal(t, int64(-9999), db.FieldMinInt(3))
+	// PRICE F 9.2
+	assert.Equal(t, int64(999999), db.FieldMaxInt(4))
+	assert.Equal(t, int64(-99999), db.FieldMinInt(4))
+	require.NoError(t, db.Error())
+
+	assert.Equal(t, int64(0), db.FieldMaxInt(1))
+	assert.EqualError(t, db.Error(), `xbase: FieldMaxInt: field 1 "NAME": type mismatch: got numeric, want "C"`)
+}
+
+func TestThreadSafe(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true, WithThreadSafe())
+	require.NoError(t, err)
+	defer db.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(recNo int64) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if err := db.GoTo(recNo); err != nil {
+					t.Error(err)
+					return
+				}
+				db.FieldValueAsString(1)
+				db.FieldValueAsInt(3)
+			}
+		}(int64(i%3 + 1))
+	}
+	wg.Wait()
+	require.NoError(t, db.Error())
+	require.NoError(t, db.GoTo(3))
+	assert.Equal(t, "Мышь", db.FieldValueAsString(1))
+
+	// moving the cursor and changing the current record
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if err := db.Next(); err == io.EOF {
+					require.NoError(t, db.First())
+				}
+				db.FieldValueAsString(1)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				db.SetFieldValue(1, "Кот")
+				db.RecDeleted()
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, db.Error())
+
+	// failing reads set the error concurrently
+	for round := 0; round < 50; round++ {
+		db.Clear()
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				db.FieldValueAsInt(10)
+			}()
+		}
+		wg.Wait()
+		assert.EqualError(t, db.Error(), "xbase: FieldValueAsInt: field 10: field number out of range")
+	}
+}
+
+func TestDBase7LongNames(t *testing.T) {
+	db := MustNew(nil)
+	require.Error(t, db.AddField("CUSTOMER_NAME", "C", 20))
+	require.NoError(t, db.SetFileType(FileTypeDBase7))
+	require.NoError(t, db.AddField("CUSTOMER_NAME", "C", 20))
+	require.NoError(t, db.AddField("ORDER_TOTAL_AMOUNT", "F", 10, 2))
+	require.Error(t, db.AddField(strings.Repeat("X", 33), "C", 1))
+	require.NoError(t, db.CreateFile("./testdata/test-dbase7.dbf"))
+	require.NoError(t, db.Add())
+	db.SetFieldValue(1, "Acme")
+	db.SetFieldValue(2, 12.5)
+	require.NoError(t, db.Save())
+	require.NoError(t, db.Close())
+
+	b := readFile("./testdata/test-dbase7.dbf")
+	assert.Equal(t, FileTypeDBase7, b[0])
+	assert.Equal(t, uint16(headerSize7+2*fieldSize7+1), binary.LittleEndian.Uint16(b[8:]))
+	assert.Equal(t, "CUSTOMER_NAME", string(b[headerSize7:headerSize7+13]))
+
+	db, err := Open("./testdata/test-dbase7.dbf", false)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, FileTypeDBase7, db.FileType())
+	assert.Equal(t, []string{"CUSTOMER_NAME", "ORDER_TOTAL_AMOUNT"}, db.Fields())
+	assert.Empty(t, db.Validate())
+	require.NoError(t, db.First())
+	assert.Equal(t, "Acme", db.FieldValueAsString(db.FieldNo("customer_name")))
+	assert.Equal(t, 12.5, db.FieldValueAsFloat(2))
+	require.NoError(t, db.RenameField("CUSTOMER_NAME", "CUSTOMER_FULL_NAME"))
+	require.NoError(t, db.Error())
+}
+
+func TestWriteEOFMarker(t *testing.T) {
+	for _, marker := range []bool{true, false} {
+		db := MustNew(nil)
+		db.SetWriteEOFMarker(marker)
+		require.NoError(t, db.AddField("NAME", "C", 5))
+		require.NoError(t, db.CreateFile("./testdata/test-eof.dbf"))
+		for _, name := range []string{"a", "b"} {
+			require.NoError(t, db.Add())
+			db.SetFieldValue(1, name)
+			require.NoError(t, db.Save())
+		}
+		require.NoError(t, db.Close())
+
+		b := readFile("./testdata/test-eof.dbf")
+		dataEnd := headerSize + fieldSize + 1 + 2*6
+		if marker {
+			require.Len(t, b, dataEnd+1)
+			assert.Equal(t, fileEnd, b[len(b)-1])
+		} else {
+			require.Len(t, b, dataEnd)
+			assert.Equal(t, byte(' '), b[len(b)-1])
+		}
+	}
+
+	// an existing marker is removed
+	buf := NewSeekableBufferWithBytes(readFile("./testdata/rec3.dbf"))
+	db, err := New(buf)
+	require.NoError(t, err)
+	db.SetWriteEOFMarker(false)
+	require.NoError(t, db.First())
+	db.SetFieldValue(1, "Xyz")
+	require.NoError(t, db.Save())
+	require.NoError(t, db.Flush())
+	assert.Equal(t, db.dataEnd(), int64(buf.Len()))
+	assert.Empty(t, db.Validate())
+}
+
+func TestRecSizeMismatch(t *testing.T) {
+	b := readFile("./testdata/rec3.dbf")
+	db, err := New(NewSeekableBufferWithBytes(b))
+	require.NoError(t, err)
+	assert.Empty(t, db.Warnings())
+
+	// shift the record size in the header and the data by one byte
+	recSize := binary.LittleEndian.Uint16(b[10:12])
+	binary.LittleEndian.PutUint16(b[10:12], recSize+1)
+	db, err = New(NewSeekableBufferWithBytes(b))
+	require.NoError(t, err)
+	require.Len(t, db.Warnings(), 1)
+	assert.EqualError(t, db.Warnings()[0], fmt.Sprintf("xbase: record size %d in header, want %d from field lengths", recSize+1, recSize))
+	assert.Error(t, db.GoTo(3))
+
+	db, err = New(NewSeekableBufferWithBytes(b), WithRecomputeRecSize())
+	require.NoError(t, err)
+	require.Len(t, db.Warnings(), 1)
+	assert.Contains(t, db.Warnings()[0].Error(), "recomputed")
+	assert.Equal(t, recSize, db.header.RecSize)
+	require.NoError(t, db.GoTo(3))
+	assert.Equal(t, "Мышь", db.FieldValueAsString(1))
+}
+
+func TestRecSizeWithoutDeletionFlag(t *testing.T) {
+	b := readFile("./testdata/rec3.dbf")
+	recSize := binary.LittleEndian.Uint16(b[10:12])
+	binary.LittleEndian.PutUint16(b[10:12], recSize-1)
+	db, err := New(NewSeekableBufferWithBytes(b))
+	require.NoError(t, err)
+	assert.Equal(t, -1, db.RecSizeDiscrepancy())
+	require.Len(t, db.Warnings(), 1)
+	assert.Contains(t, db.Warnings()[0].Error(), "deletion flag")
+	require.NoError(t, db.GoTo(3))
+	assert.NotEqual(t, "Мышь", db.FieldValueAsString(1))
+
+	db, err = New(NewSeekableBufferWithBytes(b), WithRecomputeRecSize())
+	require.NoError(t, err)
+	assert.Equal(t, -1, db.RecSizeDiscrepancy())
+	assert.Equal(t, recSize, db.header.RecSize)
+	require.NoError(t, db.GoTo(3))
+	assert.Equal(t, "Мышь", db.FieldValueAsString(1))
+	assert.Equal(t, int64(-321), db.FieldValueAsInt(3))
+
+	db, err = Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, 0, db.RecSizeDiscrepancy())
+}
+
+func TestCopyRecordsFrom(t *testing.T) {
+	src := MustNew(nil)
+	require.NoError(t, src.AddField("NAME", "C", 5))
+	require.NoError(t, src.AddField("COUNT", "N", 4))
+	require.NoError(t, src.CreateFile("./testdata/test-copy-src.dbf"))
+	for i := 1; i <= 6; i++ {
+		require.NoError(t, src.Add())
+		src.SetFieldValue(1, fmt.Sprintf("r%d", i))
+		src.SetFieldValue(2, i)
+		require.NoError(t, src.Save())
+	}
+	require.NoError(t, src.GoTo(5))
+	src.Del()
+	require.NoError(t, src.Save())
+	require.NoError(t, src.Flush())
+	defer src.Close()
+
+	dst := MustNew(nil)
+	require.NoError(t, dst.AddField("NAME", "C", 5))
+	require.NoError(t, dst.AddField("COUNT", "N", 4))
+	require.NoError(t, dst.CreateFile("./testdata/test-copy-dst.dbf"))
+	defer dst.Close()
+
+	n := 0
+	everyOther := func(rec []string) bool {
+		n++
+		return n%2 == 1
+	}
+	copied, err := dst.CopyRecordsFrom(src, everyOther)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), copied)
+	require.NoError(t, dst.GoTo(3))
+	assert.Equal(t, "r5", dst.FieldValueAsString(1))
+	assert.True(t, dst.RecDeleted())
+
+	n = 0
+	copied, err = dst.CopyRecordsFrom(src, everyOther, CopySkipDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), copied)
+	var names []string
+	for recNo := int64(4); recNo <= dst.RecCount(); recNo++ {
+		require.NoError(t, dst.GoTo(recNo))
+		names = append(names, dst.FieldValueAsString(1))
+	}
+	assert.Equal(t, []string{"r1", "r3", "r6"}, names)
+	assert.Equal(t, int64(5), src.recordNum)
+
+	other := MustNew(nil)
+	require.NoError(t, other.AddField("NAME", "C", 6))
+	require.NoError(t, other.AddField("COUNT", "N", 4))
+	_, err = other.CopyRecordsFrom(src, nil)
+	assert.Error(t, err)
+}
+
+//go:embed testdata/rec3.dbf
+var testdataFS embed.FS
+
+func TestOpenFS(t *testing.T) {
+	db, err := OpenFS(testdataFS, "testdata/rec3.dbf")
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, int64(3), db.RecCount())
+	require.NoError(t, db.GoTo(3))
+	assert.Equal(t, "Мышь", db.FieldValueAsString(1))
+
+	db.SetFieldValue(1, "Xyz")
+	assert.ErrorIs(t, db.Save(), ErrReadOnly)
+
+	_, err = OpenFS(testdataFS, "testdata/missing.dbf")
+	assert.Error(t, err)
+}
+
+func TestNumericOverflowRead(t *testing.T) {
+	db, err := New(NewSeekableBufferWithBytes(readFile("./testdata/rec3.dbf")))
+	require.NoError(t, err)
+	require.NoError(t, db.First())
+	f := db.fields[2]
+	require.NoError(t, f.setBuffer(db.buffer, strings.Repeat("*", int(f.Len))))
+
+	db.FieldValueAsInt(3)
+	assert.ErrorIs(t, db.Error(), ErrNumericOverflow)
+	assert.EqualError(t, db.Error(), `xbase: FieldValueAsInt: field 3 "COUNT": xbase: numeric overflow`)
+	db.Clear()
+
+	db.SetOverflowAsNull(true)
+	require.NoError(t, f.setBuffer(db.buffer, strings.Repeat("*", int(f.Len))))
+	assert.Equal(t, int64(0), db.FieldValueAsInt(3))
+	assert.Equal(t, 0.0, db.FieldValueAsFloat(3))
+	assert.Nil(t, db.FieldValue(3))
+	assert.Nil(t, db.FieldValueAsRat(3))
+	require.NoError(t, db.Error())
+}
+
+func TestSetDeleted(t *testing.T) {
+	db, err := New(NewSeekableBufferWithBytes(readFile("./testdata/rec3.dbf")))
+	require.NoError(t, err)
+	require.NoError(t, db.First())
+	require.NoError(t, db.SetDeleted(2, true))
+	assert.Equal(t, int64(1), db.RecNo())
+	require.NoError(t, db.GoTo(2))
+	assert.True(t, db.RecDeleted())
+	assert.Equal(t, "", db.FieldValueAsString(1))
+
+	require.NoError(t, db.SetDeleted(2, false))
+	assert.False(t, db.RecDeleted())
+	require.NoError(t, db.GoTo(2))
+	assert.False(t, db.RecDeleted())
+
+	assert.Error(t, db.SetDeleted(0, true))
+	assert.Error(t, db.SetDeleted(4, true))
+
+	ro, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer ro.Close()
+	assert.ErrorIs(t, ro.SetDeleted(1, true), ErrReadOnly)
+}
+
+func TestPreserveFieldCase(t *testing.T) {
+	db := MustNew(nil)
+	db.SetPreserveFieldCase(true)
+	require.NoError(t, db.AddField("Price", "N", 8, 2))
+	require.NoError(t, db.AddField("name", "C", 10))
+	require.Error(t, db.AddField("LongerName1", "C", 10))
+	require.NoError(t, db.CreateFile("./testdata/test-case.dbf"))
+	assert.Equal(t, 1, db.FieldNo("PRICE"))
+	assert.Equal(t, 2, db.FieldNo("Name"))
+	require.NoError(t, db.RenameField("NAME", "Title"))
+	require.NoError(t, db.Close())
+
+	db, err := Open("./testdata/test-case.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, []string{"Price", "Title"}, db.Fields())
+	assert.Equal(t, 1, db.FieldNo("price"))
+
+	upper := MustNew(nil)
+	require.NoError(t, upper.AddField("Price", "N", 8, 2))
+	assert.Equal(t, []string{"PRICE"}, upper.Fields())
+}
+
+func TestAppendMap(t *testing.T) {
+	db := MustNew(nil)
+	require.NoError(t, db.AddField("NAME", "C", 10))
+	require.NoError(t, db.AddField("COUNT", "N", 5))
+	require.NoError(t, db.AddField("FLAG", "L"))
+	require.NoError(t, db.CreateFile("./testdata/test-appendmap.dbf"))
+	defer db.Close()
+
+	require.NoError(t, db.AppendMap(map[string]interface{}{"name": "Abc", "COUNT": 12}))
+	require.NoError(t, db.Append(map[string]interface{}{"FLAG": true, "NAME": nil}))
+	assert.Equal(t, int64(2), db.RecCount())
+
+	require.NoError(t, db.First())
+	assert.Equal(t, "Abc", db.FieldValueAsString(1))
+	assert.Equal(t, int64(12), db.FieldValueAsInt(2))
+	assert.False(t, db.FieldValueAsBool(3))
+	require.NoError(t, db.Next())
+	assert.Equal(t, "", db.FieldValueAsString(1))
+	assert.True(t, db.FieldValueAsBool(3))
+
+	err := db.AppendMap(map[string]interface{}{"COUNT": "many"})
+	assert.Contains(t, err.Error(), `xbase: AppendMap: field "COUNT"`)
+	assert.Error(t, db.AppendMap(map[string]interface{}{"PRICE": 1}))
+	assert.Equal(t, int64(2), db.RecCount())
+	require.NoError(t, db.Error())
+}
+
+func writeBufferTestFile(tb testing.TB, name string, n int, opts ...Option) {
+	f, err := os.Create(name)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	db, err := New(f, opts...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	rec := &Rec{Name: "Abc", Flag: true, Count: 123, Price: 123.45, Date: time.Date(2021, 2, 12, 0, 0, 0, 0, time.UTC)}
+	for i := 0; i < n; i++ {
+		rec.Count = i
+		if err := db.AppendStruct(rec); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestWithWriteBuffer(t *testing.T) {
+	writeBufferTestFile(t, "./testdata/test-unbuffered.dbf", 500)
+	writeBufferTestFile(t, "./testdata/test-buffered.dbf", 500, WithWriteBuffer(1000))
+	assert.Equal(t, readFile("./testdata/test-unbuffered.dbf"), readFile("./testdata/test-buffered.dbf"))
+
+	db, err := Open("./testdata/test-buffered.dbf", false, WithWriteBuffer(4096))
+	require.NoError(t, err)
+	require.NoError(t, db.GoTo(10))
+	db.SetFieldValue(1, "Xyz")
+	require.NoError(t, db.Save())
+	require.NoError(t, db.Add())
+	db.SetFieldValue(1, "New")
+	require.NoError(t, db.Save())
+	// reads see the buffered writes
+	require.NoError(t, db.GoTo(10))
+	assert.Equal(t, "Xyz", db.FieldValueAsString(1))
+	require.NoError(t, db.Truncate(400))
+	require.NoError(t, db.Close())
+
+	db, err = Open("./testdata/test-buffered.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, int64(400), db.RecCount())
+	assert.Empty(t, db.Validate())
+	require.NoError(t, db.GoTo(10))
+	assert.Equal(t, "Xyz", db.FieldValueAsString(1))
+}
+
+func BenchmarkBulkInsert(b *testing.B) {
+	for _, size := range []int{0, 64 << 10} {
+		b.Run(fmt.Sprintf("buffer=%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				writeBufferTestFile(b, "./testdata/test-bulk.dbf", 10000, WithWriteBuffer(size))
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	db, err := New(NewSeekableBufferWithBytes(readFile("./testdata/rec3.dbf")))
+	require.NoError(t, err)
+	require.NoError(t, db.Sync())
+
+	f, err := ioutil.TempFile("", "xbase-sync-*.dbf")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	defer os.Remove(f.Name())
+	db = MustNew(nil)
+	require.NoError(t, db.AddField("NAME", "C", 5))
+	require.NoError(t, db.CreateFile(f.Name()))
+	require.NoError(t, db.Append(map[string]interface{}{"NAME": "a"}))
+	require.NoError(t, db.Sync())
+	require.NoError(t, db.Close())
+}
+
+func TestCopyRecordsTranscode(t *testing.T) {
+	src := MustNew(nil)
+	src.SetCodePage(866)
+	require.NoError(t, src.AddField("NAME", "C", 10))
+	require.NoError(t, src.CreateFile("./testdata/test-copy-866.dbf"))
+	defer src.Close()
+	require.NoError(t, src.Append(map[string]interface{}{"NAME": "Мышь"}))
+
+	dst := MustNew(nil)
+	dst.SetCodePage(1251)
+	require.NoError(t, dst.AddField("NAME", "C", 10))
+	require.NoError(t, dst.CreateFile("./testdata/test-copy-1251.dbf"))
+	defer dst.Close()
+
+	_, err := dst.CopyRecordsFrom(src, nil)
+	require.Error(t, err)
+	copied, err := dst.CopyRecordsFrom(src, nil, CopyTranscode())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), copied)
+	require.NoError(t, dst.First())
+	assert.Equal(t, "Мышь", dst.FieldValueAsString(1))
+	require.NoError(t, src.First())
+	assert.NotEqual(t, src.RecordBytes(), dst.RecordBytes())
+}
+
+func TestColumns(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+
+	cols := db.Columns()
+	require.Len(t, cols, db.FieldCount())
+	offset := 1
+	for i, c := range cols {
+		assert.Equal(t, i+1, c.Ordinal)
+		assert.Equal(t, db.Fields()[i], c.Name)
+		assert.Equal(t, db.FieldLength(i+1), c.Len)
+		assert.Equal(t, offset, c.Offset)
+		offset += c.Len
+	}
+	assert.Equal(t, db.RecordBytes()[cols[0].Offset:cols[0].Offset+cols[0].Len], []byte(padRight(db.FieldValueAsString(1), cols[0].Len)))
+}
+
+func TestWriteRecordAt(t *testing.T) {
+	db := MustNew(nil)
+	require.NoError(t, db.AddField("NAME", "C", 10))
+	require.NoError(t, db.AddField("COUNT", "N", 5))
+	require.NoError(t, db.CreateFile("./testdata/test-writeat.dbf"))
+	defer db.Close()
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, db.AppendMap(map[string]interface{}{"NAME": name, "COUNT": 1}))
+	}
+	require.NoError(t, db.GoTo(1))
+
+	require.NoError(t, db.WriteRecordAt(2, []interface{}{"Bob", nil}))
+	assert.Equal(t, int64(1), db.RecNo())
+	assert.Equal(t, "a", db.FieldValueAsString(1))
+
+	require.NoError(t, db.GoTo(2))
+	assert.Equal(t, "Bob", db.FieldValueAsString(1))
+	assert.Equal(t, "", db.FieldValueAsString(2))
+	assert.False(t, db.RecDeleted())
+	require.NoError(t, db.GoTo(3))
+	assert.Equal(t, "c", db.FieldValueAsString(1))
+
+	assert.Error(t, db.WriteRecordAt(4, []interface{}{"x", 1}))
+	assert.Error(t, db.WriteRecordAt(0, []interface{}{"x", 1}))
+	assert.Error(t, db.WriteRecordAt(1, []interface{}{"x"}))
+}
+
+func TestSchemaRoundTrip(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+
+	b, err := db.ExportSchema()
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"codePage": 866`)
+	assert.Contains(t, string(b), `"modDate": "`+db.ModDate().Format("2006-01-02")+`"`)
+
+	specs, err := ImportSchema(b)
+	require.NoError(t, err)
+	assert.Equal(t, []FieldSpec{
+		{Name: "NAME", Type: "C", Len: 20},
+		{Name: "FLAG", Type: "L", Len: 1},
+		{Name: "COUNT", Type: "N", Len: 5},
+		{Name: "PRICE", Type: "F", Len: 9, Dec: 2},
+		{Name: "DATE", Type: "D", Len: 8},
+	}, specs)
+
+	dst := MustNew(nil)
+	for _, spec := range specs {
+		require.NoError(t, dst.AddField(spec.Name, spec.Type, spec.Len, spec.Dec))
+	}
+	require.NoError(t, dst.CreateFile("./testdata/test-schema.dbf"))
+	defer dst.Close()
+	assert.Equal(t, db.FieldInfos(), dst.FieldInfos())
+
+	_, err = ImportSchema([]byte(`{"fields":[{"name":"NAME","type":"X","len":1}]}`))
+	assert.Error(t, err)
+	_, err = ImportSchema([]byte(`{`))
+	assert.Error(t, err)
+}
+
+func TestCount(t *testing.T) {
+	db := MustNew(nil)
+	require.NoError(t, db.AddField("NAME", "C", 5))
+	require.NoError(t, db.AddField("COUNT", "N", 4))
+	require.NoError(t, db.CreateFile("./testdata/test-count.dbf"))
+	defer db.Close()
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, db.AppendMap(map[string]interface{}{"NAME": fmt.Sprintf("r%d", i), "COUNT": i * 10}))
+	}
+	require.NoError(t, db.SetDeleted(4, true))
+	require.NoError(t, db.GoTo(2))
+
+	over := func(rec []string) bool {
+		n, _ := strconv.Atoi(rec[1])
+		return n > 20
+	}
+	n, err := db.Count(over)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	n, err = db.Count(over, CountIncludeDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	n, err = db.Count(nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+	assert.Equal(t, int64(2), db.RecNo())
+	assert.Equal(t, "r2", db.FieldValueAsString(1))
+}
+
+func TestProductionIndexWarning(t *testing.T) {
+	b := readFile("./testdata/rec3.dbf")
+	b[28] |= flagProductionIndex
+	name := "./testdata/test-mdx.dbf"
+	require.NoError(t, os.WriteFile(name, b, 0644))
+
+	db, err := Open(name, true)
+	require.NoError(t, err)
+	assert.True(t, db.HasMDX())
+	assert.Empty(t, db.Warnings())
+	require.NoError(t, db.Close())
+
+	db, err = Open(name, false)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.True(t, db.HasMDX())
+	require.Len(t, db.Warnings(), 1)
+	assert.ErrorIs(t, db.Warnings()[0], ErrIndexNotMaintained)
+
+	db2, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db2.Close()
+	assert.False(t, db2.HasMDX())
+}
+
+func TestFieldValues(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.GoTo(3))
+
+	assert.Equal(t, []string{"Мышь", "F", "-321", "-54.32", "20210212"}, db.FieldValues())
+	assert.Equal(t, int64(3), db.RecNo())
+	assert.Equal(t, db.FieldValues(), db.FieldValues())
+	assert.Equal(t, int64(3), db.RecNo())
+	require.NoError(t, db.Error())
+}
+
+func TestReadGoTo(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// the header comes first even if the cursor was moved
+	require.NoError(t, db.GoTo(2))
+	rec, err := db.Read()
+	require.NoError(t, err)
+	assert.Equal(t, db.Fields(), rec)
+	rec, err = db.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "", rec[0])
+	assert.Equal(t, int64(3), db.RecNo())
+
+	require.NoError(t, db.GoTo(1))
+	rec, err = db.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "Abc", rec[0])
+	rec, err = db.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "", rec[0])
+	rec, err = db.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "Мышь", rec[0])
+	_, err = db.Read()
+	assert.Equal(t, io.EOF, err)
+	_, err = db.Read()
+	assert.Equal(t, io.EOF, err)
+
+	require.NoError(t, db.GoTo(3))
+	rec, err = db.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "Мышь", rec[0])
+
+	// a decoder given the header reads the records from the cursor
+	db2, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db2.Close()
+	dec, err := NewDecoder(db2, db2.Fields()...)
+	require.NoError(t, err)
+	var got []map[string]string
+	require.NoError(t, dec.DecodeMaps(&got))
+	assert.Len(t, got, 3)
+}
+
+func TestImportCSVStream(t *testing.T) {
+	db := MustNew(nil)
+	require.NoError(t, db.AddField("NAME", "C", 12))
+	require.NoError(t, db.AddField("COUNT", "N", 5))
+	require.NoError(t, db.AddField("FLAG", "L"))
+	require.NoError(t, db.AddField("DATE", "D"))
+	require.NoError(t, db.CreateFile("./testdata/test-csv.dbf"))
+	defer db.Close()
+
+	in := "name,count,flag,date\n" +
+		"\"Smith, J\",12,T,20210212\n" +
+		"plain,,N,\n" +
+		"\"say \"\"hi\"\"\",-3,,20211231\n"
+	n, err := db.ImportCSVStream(strings.NewReader(in), CSVSkipHeader(), CSVFlushEvery(2))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.Equal(t, int64(3), db.RecCount())
+
+	require.NoError(t, db.First())
+	assert.Equal(t, "Smith, J", db.FieldValueAsString(1))
+	assert.Equal(t, int64(12), db.FieldValueAsInt(2))
+	assert.True(t, db.FieldValueAsBool(3))
+	require.NoError(t, db.GoTo(2))
+	assert.Equal(t, "", db.FieldValueAsString(2))
+	require.NoError(t, db.GoTo(3))
+	assert.Equal(t, `say "hi"`, db.FieldValueAsString(1))
+	assert.Equal(t, int64(-3), db.FieldValueAsInt(2))
+
+	in = "a;1;T;20210101\nb;x;T;20210101\nc;3;T;20210101\n"
+	n, err = db.ImportCSVStream(strings.NewReader(in), CSVComma(';'))
+	assert.Equal(t, int64(1), n)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `record 2: field "COUNT"`)
+	assert.Equal(t, int64(4), db.RecCount())
+
+	_, err = db.ImportCSVStream(strings.NewReader("a,1\n"))
+	assert.ErrorIs(t, err, ErrFieldCount)
+}
+
+func BenchmarkImportCSVStream(b *testing.B) {
+	const n = 1000000
+	var in bytes.Buffer
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&in, "\"name %d\",%d,T,20210212\n", i, i%100000)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db := MustNew(nil)
+		db.AddField("NAME", "C", 12)
+		db.AddField("COUNT", "N", 6)
+		db.AddField("FLAG", "L")
+		db.AddField("DATE", "D")
+		if err := db.CreateFile("./testdata/test-csv-bench.dbf"); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := db.ImportCSVStream(bytes.NewReader(in.Bytes())); err != nil {
+			b.Fatal(err)
+		}
+		if err := db.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestReplaceAll(t *testing.T) {
+	copyFile("./testdata/rec3.dbf", "./testdata/test-replace.dbf")
+	db, err := Open("./testdata/test-replace.dbf", false)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.GoTo(1))
+	name := db.FieldNo("NAME")
+
+	n, err := db.ReplaceAll(name, "Abc", "Xyz")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, int64(1), db.RecNo())
+	assert.Equal(t, "Xyz", db.FieldValueAsString(1))
+
+	n, err = db.ReplaceAll(name, nil, "Empty")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	n, err = db.ReplaceAll(db.FieldNo("COUNT"), -321, 7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	n, err = db.ReplaceAll(name, "Abc", "Xyz")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+
+	// deleted records are skipped by default
+	require.NoError(t, db.SetDeleted(2, true))
+	n, err = db.ReplaceAll(name, "Empty", "Gone")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	n, err = db.ReplaceAll(name, "Empty", "Gone", ReplaceIncludeDeleted())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	_, err = db.ReplaceAll(0, "a", "b")
+	assert.EqualError(t, err, "xbase: ReplaceAll: field number 0 out of range")
+	require.NoError(t, db.Close())
+
+	db, err = Open("./testdata/test-replace.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	dec, err := NewDecoder(db, db.Fields()...)
+	require.NoError(t, err)
+	var got []map[string]string
+	require.NoError(t, dec.DecodeMaps(&got))
+	assert.Equal(t, "Xyz", got[0]["NAME"])
+	assert.Equal(t, "Gone", got[1]["NAME"])
+	assert.Equal(t, "Мышь", got[2]["NAME"])
+	assert.Equal(t, "7", got[2]["COUNT"])
+}
+
+// createOLETestFile creates a table with a general ("G") field, which can't be
+// added by AddField, by patching the type of a character field.
+func createOLETestFile(t *testing.T, name string, blocks ...string) {
+	db := MustNew(nil)
+	require.NoError(t, db.AddField("NAME", "C", 5))
+	require.NoError(t, db.AddField("PICT", "C", 10))
+	require.NoError(t, db.CreateFile(name))
+	for i, block := range blocks {
+		require.NoError(t, db.AppendMap(map[string]interface{}{"NAME": fmt.Sprintf("r%d", i+1), "PICT": block}))
+	}
+	require.NoError(t, db.Close())
+	b := readFile(name)
+	b[headerSize+fieldSize+11] = FieldType_OLE
+	require.NoError(t, os.WriteFile(name, b, 0644))
+}
+
+func TestGeneralFieldPassthrough(t *testing.T) {
+	createOLETestFile(t, "./testdata/test-ole-src.dbf", "         1", "        17")
+	createOLETestFile(t, "./testdata/test-ole-dst.dbf")
+
+	src, err := Open("./testdata/test-ole-src.dbf", true)
+	require.NoError(t, err)
+	defer src.Close()
+	assert.Equal(t, byte(FieldType_OLE), src.FieldType(2))
+	require.NoError(t, src.GoTo(2))
+	assert.Equal(t, []byte("        17"), src.FieldValueAsBytes(2))
+
+	dst, err := Open("./testdata/test-ole-dst.dbf", false)
+	require.NoError(t, err)
+	defer dst.Close()
+	require.NoError(t, dst.Add())
+	dst.SetFieldValue(1, "r2")
+	dst.SetFieldValue(2, src.FieldValueAsBytes(2))
+	require.NoError(t, dst.Error())
+	require.NoError(t, dst.Save())
+	_, err = dst.CopyRecordsFrom(src, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, dst.First())
+	assert.Equal(t, src.RecordBytes(), dst.RecordBytes())
+	require.NoError(t, src.First())
+	require.NoError(t, dst.GoTo(2))
+	assert.Equal(t, src.RecordBytes(), dst.RecordBytes())
+
+	dst.SetFieldValue(2, []byte("1"))
+	assert.Error(t, dst.Error())
+}
+
+func TestCompact(t *testing.T) {
+	name := "./testdata/test-compact.dbf"
+	db := MustNew(nil)
+	db.SetCodePage(866)
+	require.NoError(t, db.AddField("NAME", "C", 5))
+	require.NoError(t, db.AddField("NO", "N", 4))
+	require.NoError(t, db.SetRecNoField("NO"))
+	require.NoError(t, db.CreateFile(name))
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, db.AppendMap(map[string]interface{}{"NAME": fmt.Sprintf("r%d", i)}))
+	}
+	require.NoError(t, db.Close())
+
+	db, err := Open(name, false)
+	require.NoError(t, err)
+	require.NoError(t, db.SetRecNoField("NO"))
+	require.NoError(t, db.SetDeleted(2, true))
+	require.NoError(t, db.SetDeleted(4, true))
+	require.NoError(t, db.Flush())
+	modDate := db.ModDate()
+	before, err := os.Stat(name)
+	require.NoError(t, err)
+
+	require.NoError(t, db.Compact(""))
+	assert.Equal(t, int64(3), db.RecCount())
+	assert.Equal(t, int64(0), db.RecNo())
+	require.NoError(t, db.GoTo(2))
+	assert.Equal(t, "r3", db.FieldValueAsString(1))
+	assert.Equal(t, int64(2), db.FieldValueAsInt(2))
+	require.NoError(t, db.AppendMap(map[string]interface{}{"NAME": "r6"}))
+	require.NoError(t, db.Close())
+
+	after, err := os.Stat(name)
+	require.NoError(t, err)
+	assert.False(t, os.SameFile(before, after))
+	tmp, err := filepath.Glob("./testdata/test-compact.dbf.*.tmp")
+	require.NoError(t, err)
+	assert.Empty(t, tmp)
+
+	db, err = Open(name, true)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, int64(4), db.RecCount())
+	assert.Equal(t, 866, db.CodePage())
+	assert.Equal(t, modDate.Format("20060102"), db.ModDate().Format("20060102"))
+	assert.Empty(t, db.Validate())
+	dec, err := NewDecoder(db, db.Fields()...)
+	require.NoError(t, err)
+	var got []map[string]string
+	require.NoError(t, dec.DecodeMaps(&got))
+	assert.Equal(t, []map[string]string{
+		{"NAME": "r1", "NO": "1"},
+		{"NAME": "r3", "NO": "2"},
+		{"NAME": "r5", "NO": "3"},
+		{"NAME": "r6", "NO": "4"},
+	}, got)
+}
+
+func TestCompactInPlace(t *testing.T) {
+	buf := NewSeekableBufferWithBytes(readFile("./testdata/rec3.dbf"))
+	db, err := New(buf)
+	require.NoError(t, err)
+	size := buf.Len()
+	require.NoError(t, db.SetDeleted(2, true))
+	require.NoError(t, db.Compact(""))
+	assert.Equal(t, int64(2), db.RecCount())
+	assert.Equal(t, size-int(db.header.RecSize), buf.Len())
+	require.NoError(t, db.GoTo(2))
+	assert.Equal(t, "Мышь", db.FieldValueAsString(1))
+	assert.Empty(t, db.Validate())
+}
+
+func TestProject(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.GoTo(2))
+
+	proj, err := db.Project("PRICE", "name")
+	require.NoError(t, err)
+	var got [][]string
+	for proj.Next() {
+		got = append(got, append([]string(nil), proj.Values()...))
+		assert.False(t, proj.Deleted())
+	}
+	require.NoError(t, proj.Err())
+	assert.Equal(t, [][]string{{"123.45", "Abc"}, {"", ""}, {"-54.32", "Мышь"}}, got)
+	assert.Equal(t, int64(3), proj.RecNo())
+	assert.Equal(t, int64(2), db.RecNo())
+
+	_, err = db.Project("NAME", "NOPE")
+	assert.EqualError(t, err, `xbase: Project: field "NOPE" not found`)
+	_, err = db.Project()
+	assert.Error(t, err)
+}
+
+func createWideTestFile(b *testing.B, name string, fields, records int) *XBase {
+	db := MustNew(nil)
+	for i := 1; i <= fields; i++ {
+		db.AddField(fmt.Sprintf("F%d", i), "C", 10)
+	}
+	if err := db.CreateFile(name); err != nil {
+		b.Fatal(err)
+	}
+	rec := make(map[string]interface{}, fields)
+	for i := 1; i <= fields; i++ {
+		rec[fmt.Sprintf("F%d", i)] = fmt.Sprintf("value %d", i)
+	}
+	for i := 0; i < records; i++ {
+		if err := db.AppendStruct(rec); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := db.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	return db
+}
+
+func BenchmarkScanFullDecode(b *testing.B) {
+	db := createWideTestFile(b, "./testdata/test-wide-full.dbf", 120, 2000)
+	defer db.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for err := db.First(); err == nil; err = db.Next() {
+			if v := db.FieldValues(); len(v) != 120 {
+				b.Fatal(db.Error())
+			}
+		}
+	}
+}
+
+func BenchmarkScanProjection(b *testing.B) {
+	db := createWideTestFile(b, "./testdata/test-wide-proj.dbf", 120, 2000)
+	defer db.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		proj, err := db.Project("F7", "F99")
+		if err != nil {
+			b.Fatal(err)
+		}
+		for proj.Next() {
+			_ = proj.Values()
+		}
+		if err := proj.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestConcurrentReader(t *testing.T) {
+	db := MustNew(nil)
+	db.AddField("NO", "N", 6)
+	db.AddField("NAME", "C", 12)
+	require.NoError(t, db.CreateFile("./testdata/test-concurrent.dbf"))
+	defer os.Remove("./testdata/test-concurrent.dbf")
+	const n = 200
+	for i := 1; i <= n; i++ {
+		require.NoError(t, db.AppendMap(map[string]interface{}{"NO": i, "NAME": fmt.Sprintf("name %d", i)}))
+	}
+	require.NoError(t, db.Close())
+
+	f, err := os.Open("./testdata/test-concurrent.dbf")
+	require.NoError(t, err)
+	defer f.Close()
+	fi, err := f.Stat()
+	require.NoError(t, err)
+	cr, err := OpenReaderAt(f, fi.Size())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"NO", "NAME"}, cr.Fields())
+	assert.Equal(t, int64(n), cr.RecCount())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				recNo := int64((i+g*25)%n + 1)
+				val, err := cr.ReadRecord(recNo)
+				if err == nil && (val[0] != strconv.FormatInt(recNo, 10) || val[1] != fmt.Sprintf("name %d", recNo)) {
+					err = fmt.Errorf("record %d: got %v", recNo, val)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	_, err = cr.ReadRecord(0)
+	assert.Equal(t, BOF, err)
+	_, err = cr.ReadRecord(n + 1)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestConcurrentReaderCodePage(t *testing.T) {
+	b := readFile("./testdata/rec3.dbf")
+	cr, err := OpenReaderAt(bytes.NewReader(b), int64(len(b)))
+	require.NoError(t, err)
+	val, err := cr.ReadRecord(3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Мышь", "F", "-321", "-54.32", "20210212"}, val)
+}
+
+func TestTruncatePolicy(t *testing.T) {
+	db, err := New(nil, WithTruncatePolicy(TruncateCharBoundary))
+	require.NoError(t, err)
+	db.AddField("NAME", "C", 7)
+	require.NoError(t, db.CreateFile("./testdata/test-truncate.dbf"))
+	defer os.Remove("./testdata/test-truncate.dbf")
+	defer db.Close()
+
+	// without a code page the value is written as UTF-8, 3 bytes a character
+	require.NoError(t, db.AppendMap(map[string]interface{}{"NAME": "中文字符"}))
+	assert.Equal(t, "中文", db.FieldValueAsString(1))
+
+	db.truncPolicy = TruncateError
+	err = db.AppendMap(map[string]interface{}{"NAME": "中文字符"})
+	var foe *FieldOverflowError
+	assert.ErrorAs(t, err, &foe)
+}
+
+func TestHeaderValues(t *testing.T) {
+	db, err := Open("./testdata/rec3.dbf", true)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, FileTypeDBase3, db.FileType())
+	// 32 bytes header, 5 field descriptors and the terminator
+	assert.Equal(t, headerSize+5*fieldSize+1, db.DataOffset())
+	assert.Equal(t, 193, db.DataOffset())
+	// the deletion flag and the fields NAME 20, FLAG 1, COUNT 5, PRICE 9, DATE 8
+	assert.Equal(t, 44, db.RecSize())
+	assert.Equal(t, db.RecSize(), int(db.calcRecSize()))
+}